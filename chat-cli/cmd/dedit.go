@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danieljhkim/chat-cli/internal/config"
+	"github.com/danieljhkim/chat-cli/internal/net"
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+var dmEditCmd = &cobra.Command{
+	Use:     "edit <message-id> <new message>",
+	Short:   "Edit a direct message you sent",
+	Long:    "Replace the text of a direct message you sent. The ID is printed by `chat-cli dm send`.",
+	Example: "chat-cli dm edit 3f9c2a1b7d4e5f60 See you at 6, not 5",
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return amendDirectMessage(&protocol.WireMessage{
+			Type:  protocol.TypeEdit,
+			MsgID: args[0],
+			Body:  strings.Join(args[1:], " "),
+		})
+	},
+}
+
+var dmDeleteCmd = &cobra.Command{
+	Use:     "delete <message-id>",
+	Short:   "Delete a direct message you sent",
+	Long:    "Retract a direct message you sent. It is removed from the recipient's inbox, including if it has not been delivered yet.",
+	Example: "chat-cli dm delete 3f9c2a1b7d4e5f60",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return amendDirectMessage(&protocol.WireMessage{
+			Type:  protocol.TypeDelete,
+			MsgID: args[0],
+		})
+	},
+}
+
+// amendDirectMessage sends an edit or delete request for a DM
+func amendDirectMessage(req *protocol.WireMessage) error {
+	cfg, err := config.Get()
+	if err != nil {
+		return err
+	}
+	sess, err := dialServer(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer sess.Close()
+	if err := sess.CheckBody(req.Body); err != nil {
+		return err
+	}
+
+	resp, err := sess.Request(req)
+	if err != nil {
+		return err
+	}
+	switch resp.Type {
+	case protocol.TypeMessageEdited:
+		fmt.Printf("✏️  Message to %s edited\n", resp.Target)
+	case protocol.TypeMessageDeleted:
+		fmt.Printf("🗑️  Message to %s deleted\n", resp.Target)
+	case protocol.TypeError:
+		return net.ResponseError(resp)
+	default:
+		return fmt.Errorf("unexpected response type: %s", resp.Type)
+	}
+	return nil
+}
+
+func init() {
+	dmCmd.AddCommand(dmEditCmd)
+	dmCmd.AddCommand(dmDeleteCmd)
+}
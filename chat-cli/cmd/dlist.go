@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/danieljhkim/chat-cli/internal/config"
@@ -35,42 +34,26 @@ func runDMListCommand(cmd *cobra.Command, args []string) error {
 }
 
 func fetchDMList(cfg *config.Config) ([]protocol.DM, error) {
-	conn, err := net.Connect(cfg.ServerAddress)
+	sess, err := dialServer(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
-	defer conn.Close()
+	defer sess.Close()
 
-	enc := json.NewEncoder(conn)
-	dec := json.NewDecoder(conn)
-
-	if err := sendDMListRequest(enc, cfg.Username); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-
-	dms, err := receiveDMListResponse(dec)
-	if err != nil {
-		return nil, fmt.Errorf("failed to receive response: %w", err)
-	}
-
-	return dms, nil
+	return requestDMList(sess, cfg.Username)
 }
 
-func sendDMListRequest(enc *json.Encoder, username string) error {
+func requestDMList(sess *net.Session, username string) ([]protocol.DM, error) {
 	req := protocol.WireMessage{
 		Type:     protocol.TypeListDM,
 		Username: username,
 	}
-	return enc.Encode(req)
-}
-
-func receiveDMListResponse(dec *json.Decoder) ([]protocol.DM, error) {
-	var resp protocol.WireMessage
-	if err := dec.Decode(&resp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	resp, err := sess.Request(&req)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := validateDMlistResponse(&resp); err != nil {
+	if err := validateDMlistResponse(resp); err != nil {
 		return nil, err
 	}
 
@@ -82,7 +65,7 @@ func validateDMlistResponse(resp *protocol.WireMessage) error {
 	case protocol.TypeDMList:
 		return nil
 	case protocol.TypeError:
-		return fmt.Errorf("server error: %s", resp.Message)
+		return net.ResponseError(resp)
 	default:
 		return fmt.Errorf("unexpected response type: %s", resp.Type)
 	}
@@ -97,7 +80,14 @@ func displayDM(dms []protocol.DM) {
 	fmt.Printf("\nTotal: %d DM(s)\n", len(dms))
 	fmt.Println("Available DM's:")
 	for _, dm := range dms {
-		fmt.Printf("  [%s] %s: %s\n", dm.TimeStamp, dm.Sender, dm.Body)
+		fmt.Printf("  [%s] %s: %s", dm.Timestamp.Local().Format("2006-01-02 15:04:05"), dm.Sender, dm.Body)
+		if dm.Edited {
+			fmt.Print(" \033[2m(edited)\033[0m")
+		}
+		if dm.ID != "" {
+			fmt.Printf("  (id %s)", dm.ID)
+		}
+		fmt.Println()
 	}
 }
 
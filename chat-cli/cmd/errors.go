@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/danieljhkim/chat-cli/internal/net"
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+)
+
+// Exit codes returned by chat-cli
+const (
+	exitError       = 1 // anything not listed below
+	exitBadRequest  = 2 // server rejected the request as malformed or unsupported
+	exitAuth        = 3 // not logged in, bad credentials or not allowed
+	exitNotFound    = 4 // room or user does not exist
+	exitRateLimited = 5 // rate limited or temporarily banned
+	exitServer      = 6 // server-side failure
+	exitConnection  = 7 // connection to the server was lost
+)
+
+// serverErrorHints turns error codes into advice for the user
+var serverErrorHints = map[string]string{
+	protocol.ErrCodeInvalidPayload:     "The server rejected the request as malformed.",
+	protocol.ErrCodeUnknownType:        "The server does not support this command; it may be older than chat-cli.",
+	protocol.ErrCodeInvalidState:       "The request was sent at the wrong time; try again.",
+	protocol.ErrCodeUnsupportedVersion: "This chat-cli does not speak the server's protocol version; upgrade chat-cli.",
+	protocol.ErrCodeNotAuthorized:      "You are not allowed to do that. Join the room first, or run `chat-cli init` to log in.",
+	protocol.ErrCodeAuthFailed:         "Login failed. Run `chat-cli init` to log in again.",
+	protocol.ErrCodeUserExists:         "That username is taken; pick another or log in instead.",
+	protocol.ErrCodeUserNotFound:       "No such user; check the username.",
+	protocol.ErrCodeRoomNotFound:       "No such room; run `chat-cli rooms list` to see available rooms.",
+	protocol.ErrCodeMessageNotFound:    "No such message; it may have been deleted or be too old to change.",
+	protocol.ErrCodeRateLimited:        "You are sending too fast; wait a moment and try again.",
+	protocol.ErrCodeBanned:             "You are banned from this server; bans for flooding expire after a few minutes.",
+	protocol.ErrCodeSlowConsumer:       "Your connection fell too far behind and the server dropped it; try again.",
+	protocol.ErrCodeMessageTooLarge:    "The message is longer than the server allows; shorten it and try again.",
+	protocol.ErrCodeInternal:           "The server hit an internal error; try again later.",
+}
+
+var serverErrorExitCodes = map[string]int{
+	protocol.ErrCodeInvalidPayload:     exitBadRequest,
+	protocol.ErrCodeUnknownType:        exitBadRequest,
+	protocol.ErrCodeInvalidState:       exitBadRequest,
+	protocol.ErrCodeUnsupportedVersion: exitBadRequest,
+	protocol.ErrCodeNotAuthorized:      exitAuth,
+	protocol.ErrCodeAuthFailed:         exitAuth,
+	protocol.ErrCodeUserExists:         exitAuth,
+	protocol.ErrCodeUserNotFound:       exitNotFound,
+	protocol.ErrCodeRoomNotFound:       exitNotFound,
+	protocol.ErrCodeMessageNotFound:    exitNotFound,
+	protocol.ErrCodeRateLimited:        exitRateLimited,
+	protocol.ErrCodeBanned:             exitRateLimited,
+	protocol.ErrCodeSlowConsumer:       exitConnection,
+	protocol.ErrCodeMessageTooLarge:    exitBadRequest,
+	protocol.ErrCodeInternal:           exitServer,
+}
+
+// describeError formats err for the user, replacing server error codes
+// with a friendly hint.
+func describeError(err error) string {
+	var serr *net.ServerError
+	if errors.As(err, &serr) {
+		if hint, ok := serverErrorHints[serr.Code]; ok {
+			return fmt.Sprintf("%s\n%s", serr.Message, hint)
+		}
+		return serr.Message
+	}
+	return err.Error()
+}
+
+// exitCode picks the process exit status for err.
+func exitCode(err error) int {
+	if errors.Is(err, net.ErrConnectionLost) {
+		return exitConnection
+	}
+	var serr *net.ServerError
+	if errors.As(err, &serr) {
+		if code, ok := serverErrorExitCodes[serr.Code]; ok {
+			return code
+		}
+	}
+	return exitError
+}
+
+// Exit prints err and exits with the matching exit code.
+func Exit(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %s\n", describeError(err))
+	os.Exit(exitCode(err))
+}
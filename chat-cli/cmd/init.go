@@ -7,13 +7,15 @@ import (
 	"strings"
 
 	"github.com/danieljhkim/chat-cli/internal/config"
+	"github.com/danieljhkim/chat-cli/internal/net"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var InitCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Configure the chat CLI",
-	Long:  "Initialize the chat CLI by setting up server address and username configuration.",
+	Long:  "Initialize the chat CLI by setting up server address and username configuration, then register or log in to obtain a session token.",
 	RunE:  runInit,
 }
 
@@ -22,11 +24,16 @@ func runInit(cmd *cobra.Command, args []string) error {
 }
 
 func PromptInitAndSave(args ...string) error {
-	cfg, err := promptForConfig()
+	reader := bufio.NewReader(os.Stdin)
+	cfg, err := promptForConfig(reader)
 	if err != nil {
 		return fmt.Errorf("failed to collect configuration: %w", err)
 	}
 
+	if err := promptLogin(reader, cfg); err != nil {
+		return err
+	}
+
 	configPath, err := config.GetConfigPath()
 	if err != nil {
 		return fmt.Errorf("failed to determine config path: %w", err)
@@ -40,8 +47,7 @@ func PromptInitAndSave(args ...string) error {
 	return nil
 }
 
-func promptForConfig() (*config.Config, error) {
-	reader := bufio.NewReader(os.Stdin)
+func promptForConfig(reader *bufio.Reader) (*config.Config, error) {
 	serverAddr, err := promptInput(reader, "Enter server address (e.g. localhost:9000): ")
 	if err != nil {
 		return nil, fmt.Errorf("failed to read server address: %w", err)
@@ -50,10 +56,64 @@ func promptForConfig() (*config.Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read username: %w", err)
 	}
-	return &config.Config{
+	tlsMode, err := promptInput(reader, "TLS mode - off, verify or tofu [off]: ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tls mode: %w", err)
+	}
+	cfg := &config.Config{
 		ServerAddress: serverAddr,
 		Username:      username,
-	}, nil
+		TLSMode:       strings.ToLower(tlsMode),
+	}
+	if cfg.TLSMode == config.TLSVerify {
+		caFile, err := promptInput(reader, "CA bundle path (blank for system roots): ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle path: %w", err)
+		}
+		cfg.TLSCAFile = caFile
+	}
+	return cfg, nil
+}
+
+// promptLogin registers or logs in with a password and stores the issued token in cfg.
+func promptLogin(reader *bufio.Reader, cfg *config.Config) error {
+	answer, err := promptInput(reader, "Register a new account? (y/N): ")
+	if err != nil {
+		return fmt.Errorf("failed to read answer: %w", err)
+	}
+	register := strings.EqualFold(answer, "y") || strings.EqualFold(answer, "yes")
+
+	password, err := promptPassword(reader, "Enter password: ")
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+
+	token, err := net.Authenticate(cfg, password, register)
+	if err != nil {
+		return err
+	}
+	cfg.Token = token
+	if register {
+		fmt.Printf("✅ Registered as %s\n", cfg.Username)
+	} else {
+		fmt.Printf("✅ Logged in as %s\n", cfg.Username)
+	}
+	return nil
+}
+
+// promptPassword reads a password without echo when stdin is a terminal.
+func promptPassword(reader *bufio.Reader, prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return promptInput(reader, prompt)
+	}
+	fmt.Print(prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
 }
 
 func promptInput(reader *bufio.Reader, prompt string) (string, error) {
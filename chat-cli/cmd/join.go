@@ -6,17 +6,19 @@ package cmd
 import (
 	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/danieljhkim/chat-cli/internal/config"
-	cnet "github.com/danieljhkim/chat-cli/internal/net"
+	"github.com/danieljhkim/chat-cli/internal/net"
 	"github.com/danieljhkim/chat-cli/internal/protocol"
 	"github.com/spf13/cobra"
 )
@@ -26,21 +28,200 @@ var roomsJoinCmd = &cobra.Command{
 	Short:   "Join a chat room",
 	Long:    `Join a chat room and start participating in real-time conversations.`,
 	Args:    cobra.MinimumNArgs(1),
-	Example: "chat-cli rooms join general",
+	Example: "chat-cli rooms join general --history 50",
 	RunE:    runJoinCommand,
 }
 
+// defaultHistoryLimit is how many messages /history shows without an argument
+const defaultHistoryLimit = 20
+
+var joinHistory int
+
 // chatSession holds the state of the current chat session
 type chatSession struct {
 	roomName       string
 	username       string
-	conn           net.Conn
-	enc            *json.Encoder
-	dec            *json.Decoder
 	userCount      int
 	messageCount   int
 	startTime      time.Time
 	showTimestamps bool
+
+	gap     seqRange      // missed messages being fetched, zero when none
+	retryIn time.Duration // server_shutdown hint; the next reconnect waits this long first
+
+	// mu guards the fields below: the reader goroutine records what
+	// arrives while the input goroutine sends and resolves #n references
+	mu      sync.Mutex
+	sess    *net.Session      // nil while reconnecting
+	pending map[string]string // request ID -> request type, until the reply arrives
+	lastSeq uint64            // newest room message seen, where a reconnect resumes from
+	msgIDs  map[uint64]string // seq -> msg_id of room messages seen, for /edit and /delete
+	lastOwn uint64            // seq of our newest message, the default for /edit and /delete
+}
+
+// errNotConnected is returned by send while the session is reconnecting
+var errNotConnected = errors.New("not connected; reconnecting to the server")
+
+// send writes msg and remembers its ID so the reply can be matched to it
+func (s *chatSession) send(msg *protocol.WireMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sess == nil {
+		return errNotConnected
+	}
+	id, err := s.sess.Send(msg)
+	if err != nil {
+		return err
+	}
+	s.pending[id] = msg.Type
+	return nil
+}
+
+// checkBody reports a message too long for the server before it is sent
+func (s *chatSession) checkBody(body string) error {
+	if sess := s.current(); sess != nil {
+		return sess.CheckBody(body)
+	}
+	return nil
+}
+
+// current returns the live connection, or nil while reconnecting
+func (s *chatSession) current() *net.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sess
+}
+
+// swap replaces the connection and forgets requests sent on the old one
+func (s *chatSession) swap(sess *net.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sess != nil {
+		s.sess.Close()
+	}
+	s.sess = sess
+	s.pending = make(map[string]string)
+}
+
+// seqRange is an inclusive range of room sequence numbers
+type seqRange struct{ from, to uint64 }
+
+// checkGap notices a room message that skips sequence numbers, meaning
+// messages were lost on the way (e.g. dropped while we fell behind), and
+// asks the server for the missing ones. Call it before seen.
+func (s *chatSession) checkGap(msg *protocol.WireMessage) {
+	last := s.latest()
+	if msg.Room != s.roomName || last == 0 || msg.Seq <= last+1 {
+		return
+	}
+	gap := seqRange{last + 1, msg.Seq - 1}
+	fmt.Printf("⚠️  Missed %d message(s) (#%d-#%d); fetching them...\n", gap.to-gap.from+1, gap.from, gap.to)
+	req := protocol.NewHistoryRequest(s.roomName, 0)
+	req.Since = last
+	if err := s.send(req); err != nil {
+		fmt.Printf("❌ Could not fetch missed messages: %v\n", err)
+		return
+	}
+	s.gap = gap
+}
+
+// seen advances lastSeq past msg and remembers room messages for /edit
+// and /delete
+func (s *chatSession) seen(msg *protocol.WireMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.Seq > s.lastSeq {
+		s.lastSeq = msg.Seq
+	}
+	if msg.Type != protocol.TypeRoomMsg && msg.Type != protocol.TypeAction {
+		return
+	}
+	if msg.MsgID != "" && msg.Seq > 0 {
+		s.msgIDs[msg.Seq] = msg.MsgID
+	}
+	if msg.Username == s.username && !msg.Deleted && msg.Seq > s.lastOwn {
+		s.lastOwn = msg.Seq
+	}
+}
+
+// target resolves the message an /edit or /delete refers to: "#<seq>" as
+// the first argument selects one, otherwise it is our last message. It
+// returns the msg_id and the remaining arguments.
+func (s *chatSession) target(args []string) (string, []string, error) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "#") {
+		id, err := s.resolve(args[0])
+		return id, args[1:], err
+	}
+	s.mu.Lock()
+	own := s.lastOwn
+	s.mu.Unlock()
+	if own == 0 {
+		return "", nil, fmt.Errorf("you have no message here to change; pick one with #<n>")
+	}
+	id, err := s.resolve(fmt.Sprintf("#%d", own))
+	return id, args, err
+}
+
+// latest returns the newest room sequence number seen
+func (s *chatSession) latest() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeq
+}
+
+// forgetOwn drops seq as the default for /edit and /delete once it is deleted
+func (s *chatSession) forgetOwn(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastOwn == seq {
+		s.lastOwn = 0
+	}
+}
+
+// resolve turns a message reference into a msg_id: "#<seq>" for a message
+// seen in this session, anything else is taken as a msg_id
+func (s *chatSession) resolve(ref string) (string, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return ref, nil
+	}
+	seq, err := strconv.ParseUint(ref[1:], 10, 64)
+	if err != nil || seq == 0 {
+		return "", fmt.Errorf("%s is not a message number; use #<n> as shown with /time", ref)
+	}
+	s.mu.Lock()
+	id, ok := s.msgIDs[seq]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("message #%d is not in this session; try /history first", seq)
+	}
+	return id, nil
+}
+
+// answered returns the type of the request msg replies to, or "" if msg
+// is not a reply to one of ours
+func (s *chatSession) answered(msg *protocol.WireMessage) string {
+	if msg.ReplyTo == "" {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqType := s.pending[msg.ReplyTo]
+	delete(s.pending, msg.ReplyTo)
+	return reqType
+}
+
+// requestLabels names requests in error output
+var requestLabels = map[string]string{
+	protocol.TypeRoomMsg:    "message",
+	protocol.TypeAction:     "/me",
+	protocol.TypeListUsers:  "/users",
+	protocol.TypeGetHistory: "/history",
+	protocol.TypeLeave:      "leave",
+	protocol.TypeEdit:       "/edit",
+	protocol.TypeDelete:     "/delete",
+	protocol.TypeGetThread:  "/thread",
+	protocol.TypeReact:      "/react",
+	protocol.TypeUnreact:    "/unreact",
 }
 
 // runJoinCommand handles the main logic for joining a room
@@ -57,62 +238,61 @@ func runJoinCommand(cmd *cobra.Command, args []string) error {
 		userName = cfg.Username
 	}
 	// Establish connection and join room
-	conn, enc, dec, err := connectAndJoinRoom(roomName)
+	sess, err := connectAndJoinRoom(roomName, joinHistory, "", 0)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
 
 	session := &chatSession{
 		roomName:       roomName,
 		username:       userName,
-		conn:           conn,
-		enc:            enc,
-		dec:            dec,
+		sess:           sess,
 		startTime:      time.Now(),
 		showTimestamps: false,
+		pending:        make(map[string]string),
+		msgIDs:         make(map[uint64]string),
 	}
+	defer session.swap(nil)
 	printWelcome(session)
 	return startAdvancedChatSession(session)
 }
 
 // connectAndJoinRoom establishes connection and sends join request
-func connectAndJoinRoom(roomName string) (net.Conn, *json.Encoder, *json.Decoder, error) {
+// and asks for up to historyLimit messages of scrollback. A reconnect
+// passes the previous resume token and asks for everything after since.
+func connectAndJoinRoom(roomName string, historyLimit int, resumeToken string, since uint64) (*net.Session, error) {
 	cfg, err := config.Get()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
 	}
-	conn, err := cnet.Connect(cfg.ServerAddress)
+	sess, err := resumeServer(cfg, resumeToken)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to connect to server: %w", err)
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
 
-	enc := json.NewEncoder(conn)
-	dec := json.NewDecoder(conn)
-
 	// Send join request
 	joinReq := protocol.WireMessage{
 		Type:     protocol.TypeJoin,
 		Room:     roomName,
 		Username: cfg.Username,
+		Limit:    historyLimit,
+		Since:    since,
 	}
-	if err := enc.Encode(joinReq); err != nil {
-		conn.Close()
-		return nil, nil, nil, fmt.Errorf("failed to send join request: %w", err)
-	}
-
-	// Read join response
-	var resp protocol.WireMessage
-	if err := dec.Decode(&resp); err != nil {
-		conn.Close()
-		return nil, nil, nil, fmt.Errorf("failed to read join response: %w", err)
+	resp, err := sess.Request(&joinReq)
+	if err != nil {
+		sess.Close()
+		return nil, err
 	}
-	if resp.Type == protocol.TypeError {
-		conn.Close()
-		return nil, nil, nil, fmt.Errorf("server error: %s", resp.Message)
+	switch resp.Type {
+	case protocol.TypeUserJoined:
+		return sess, nil
+	case protocol.TypeError:
+		sess.Close()
+		return nil, fmt.Errorf("failed to join %s: %w", roomName, net.ResponseError(resp))
+	default:
+		sess.Close()
+		return nil, fmt.Errorf("unexpected join response type: %s", resp.Type)
 	}
-
-	return conn, enc, dec, nil
 }
 
 // printWelcome displays an enhanced welcome screen
@@ -130,6 +310,13 @@ func printWelcome(session *chatSession) {
 	fmt.Println("║ /users     - List users in room                          ║")
 	fmt.Println("║ /stats     - Show session statistics                     ║")
 	fmt.Println("║ /time      - Toggle timestamps                           ║")
+	fmt.Println("║ /history   - Show recent messages (/history [n])         ║")
+	fmt.Println("║ /edit      - Edit your last message (/edit [#n] <text>)  ║")
+	fmt.Println("║ /delete    - Delete your last message (/delete [#n])     ║")
+	fmt.Println("║ /reply     - Reply in a thread (/reply <#n> <text>)      ║")
+	fmt.Println("║ /thread    - Show a whole thread (/thread <#n>)          ║")
+	fmt.Println("║ /react     - React to a message (/react <#n> :+1:)       ║")
+	fmt.Println("║ /unreact   - Take a reaction back (/unreact <#n> :+1:)   ║")
 	fmt.Println("║ /clear     - Clear screen                                ║")
 	fmt.Println("║ /quit      - Exit chat                                   ║")
 	fmt.Println("║ Ctrl+C     - Exit gracefully                             ║")
@@ -179,23 +366,82 @@ func handleAdvancedIncomingMessages(ctx context.Context, session *chatSession, e
 		case <-ctx.Done():
 			return
 		default:
-			var msg protocol.WireMessage
-			if err := session.dec.Decode(&msg); err != nil {
+			sess := session.current()
+			if sess == nil {
+				return // session closed
+			}
+			msg, err := sess.Receive()
+			if err != nil {
+				if ctx.Err() != nil {
+					return // we are shutting down and closed the connection ourselves
+				}
+				if errors.Is(err, net.ErrConnectionLost) {
+					if err = session.reconnect(ctx); err == nil {
+						continue
+					}
+					fmt.Println("\n🔌 Connection lost: the server closed the connection or stopped responding.")
+				}
 				errChan <- fmt.Errorf("error reading message: %w", err)
 				return
 			}
 			session.messageCount++
+			if msg.Type == protocol.TypeRoomMsg || msg.Type == protocol.TypeAction {
+				session.checkGap(msg)
+			}
+			session.seen(msg)
+			reqType := session.answered(msg)
 			switch msg.Type {
 			case protocol.TypeRoomMsg:
-				displayChatMessage(session, &msg)
+				displayChatMessage(session, msg)
+			case protocol.TypeAction:
+				displayActionMessage(session, msg)
+			case protocol.TypeHistory:
+				for i := range msg.History {
+					session.seen(&msg.History[i])
+				}
+				switch {
+				case session.gap.from > 0 && msg.Since == session.gap.from-1:
+					displayGap(session, msg.History)
+				case msg.Since > 0:
+					displayMissed(session, msg.History)
+				default:
+					displayHistory(session, msg.History)
+				}
+			case protocol.TypeThread:
+				for i := range msg.History {
+					session.seen(&msg.History[i])
+				}
+				displayThread(session, msg)
+			case protocol.TypeMessageEdited, protocol.TypeMessageDeleted:
+				if msg.Room == "" {
+					printNotice(msg) // a DM
+				} else {
+					displayAmended(session, msg)
+				}
+			case protocol.TypeReactions:
+				displayReactionUpdate(session, msg)
 			case protocol.TypeUserJoined:
-				fmt.Printf("🟢 %s joined the room\n", msg.Username)
+				session.userCount = msg.UserCount
+				fmt.Printf("🟢 %s joined the room (%d online)\n", msg.Username, msg.UserCount)
 			case protocol.TypeUserLeft:
-				fmt.Printf("🔴 %s left the room\n", msg.Username)
+				session.userCount = msg.UserCount
+				fmt.Printf("🔴 %s left the room (%d online)\n", msg.Username, msg.UserCount)
 			case protocol.TypeUserList:
 				displayUserList(msg.Users)
+			case protocol.TypeDM, protocol.TypeInfo, protocol.TypeWarning:
+				printNotice(msg)
+			case protocol.TypeServerShutdown:
+				// drop the connection now; the next Receive reconnects
+				printNotice(msg)
+				session.retryIn = time.Duration(msg.RetryAfter) * time.Second
+				sess.Close()
 			case protocol.TypeError:
-				fmt.Printf("❌ Server error: %s\n", msg.Message)
+				text := describeError(net.ResponseError(msg))
+				if label, ok := requestLabels[reqType]; ok {
+					fmt.Printf("❌ %s failed: %s\n", label, text)
+				} else {
+					fmt.Printf("❌ Server error: %s\n", text)
+				}
 			default:
 				fmt.Printf("❓ Unknown message type: %s\n", msg.Type)
 			}
@@ -207,15 +453,266 @@ func handleAdvancedIncomingMessages(ctx context.Context, session *chatSession, e
 func displayChatMessage(session *chatSession, msg *protocol.WireMessage) {
 	timestamp := ""
 	if session.showTimestamps {
-		timestamp = fmt.Sprintf("[%s] ", time.Now().Format("15:04:05"))
+		timestamp = stamp(msg, "15:04:05")
 	}
 
 	// Highlight own messages
 	if msg.Username == session.username {
 		// fmt.Printf("%s\033[36m[You]\033[0m: %s\n", timestamp, msg.Body)
+		return
+	}
+	displayQuote(session, msg)
+	if msg.Deleted {
+		fmt.Printf("%s\033[2m[%s]: message deleted\033[0m\n", timestamp, msg.Username)
 	} else {
-		fmt.Printf("%s\033[33m[%s]\033[0m: %s\n", timestamp, msg.Username, msg.Body)
+		fmt.Printf("%s%s\033[33m[%s]\033[0m: %s%s\n", bell(session, msg), timestamp, msg.Username, highlightMentions(session.username, msg), editedMark(msg))
+	}
+	displayReactions(session, msg)
+}
+
+// displayActionMessage formats and displays a /me action
+func displayActionMessage(session *chatSession, msg *protocol.WireMessage) {
+	timestamp := ""
+	if session.showTimestamps {
+		timestamp = stamp(msg, "15:04:05")
+	}
+	displayQuote(session, msg)
+	if msg.Deleted {
+		fmt.Printf("%s\033[2m* %s: message deleted\033[0m\n", timestamp, msg.Username)
+		return
 	}
+	fmt.Printf("%s%s\033[35m* %s %s\033[0m%s\n", bell(session, msg), timestamp, msg.Username, highlightMentions(session.username, msg), editedMark(msg))
+	displayReactions(session, msg)
+}
+
+// displayAmended re-renders a room message that was edited, or notes that
+// it was deleted
+func displayAmended(session *chatSession, msg *protocol.WireMessage) {
+	name := msg.Username
+	if name == session.username {
+		name = "You"
+	}
+	ref := "a message"
+	if msg.Seq > 0 {
+		ref = fmt.Sprintf("message #%d", msg.Seq)
+	}
+	by := ""
+	if msg.Message != "" {
+		by = " (" + msg.Message + ")"
+	}
+	if msg.Type == protocol.TypeMessageDeleted {
+		if msg.Username == session.username {
+			session.forgetOwn(msg.Seq)
+		}
+		fmt.Printf("🗑️  %s deleted %s%s\n", name, ref, by)
+		return
+	}
+	timestamp := ""
+	if session.showTimestamps {
+		timestamp = stamp(msg, "15:04:05")
+	}
+	fmt.Printf("✏️  %s\033[33m[%s]\033[0m: %s%s%s\n", timestamp, name, highlightMentions(session.username, msg), editedMark(msg), by)
+}
+
+// highlightMentions returns msg's body with @mentions of username in reverse
+// video. Only mentions the server confirmed are highlighted.
+func highlightMentions(username string, msg *protocol.WireMessage) string {
+	if !slices.Contains(msg.Mentions, username) {
+		return msg.Body
+	}
+	body, at := msg.Body, "@"+username
+	var b strings.Builder
+	last := 0
+	for i := 0; ; {
+		j := strings.Index(body[i:], at)
+		if j < 0 {
+			break
+		}
+		start, end := i+j, i+j+len(at)
+		if isMentionAt(body, start, end) {
+			b.WriteString(body[last:start])
+			b.WriteString("\033[1;7m" + at + "\033[22;27m")
+			last = end
+		}
+		i = end
+	}
+	b.WriteString(body[last:])
+	return b.String()
+}
+
+// isMentionAt reports whether body[start:end] is a whole @name, matching
+// how the server finds mentions: not part of an address like
+// bob@example.com, and not the start of a longer name, though trailing
+// dots and dashes are taken as punctuation
+func isMentionAt(body string, start, end int) bool {
+	if start > 0 && isNameByte(body[start-1]) {
+		return false
+	}
+	tail := strings.TrimLeft(body[end:], ".-")
+	return tail == "" || !isNameByte(tail[0])
+}
+
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-'
+}
+
+// bell rings the terminal bell for a message that mentions us, unless we
+// wrote it
+func bell(session *chatSession, msg *protocol.WireMessage) string {
+	if msg.Username == session.username || !slices.Contains(msg.Mentions, session.username) {
+		return ""
+	}
+	return "\a"
+}
+
+// editedMark flags a message whose body was changed after sending
+func editedMark(msg *protocol.WireMessage) string {
+	if !msg.Edited {
+		return ""
+	}
+	return " \033[2m(edited)\033[0m"
+}
+
+// displayHistory renders scrollback above the live stream, including own messages
+func displayHistory(session *chatSession, history []protocol.WireMessage) {
+	if len(history) == 0 {
+		fmt.Println("📜 No earlier messages.")
+		return
+	}
+	fmt.Printf("📜 ── last %d message(s) ──\n", len(history))
+	for _, msg := range history {
+		displayQuote(session, &msg)
+		fmt.Println(historyLine(session, &msg))
+		displayReactions(session, &msg)
+	}
+	fmt.Println("📜 ── end of history ──")
+}
+
+// historyLine formats a stored message with its date, own ones included
+func historyLine(session *chatSession, msg *protocol.WireMessage) string {
+	when := stamp(msg, "01-02 15:04")
+	name := msg.Username
+	if name == session.username {
+		name = "You"
+	}
+	switch {
+	case msg.Deleted:
+		return fmt.Sprintf("%s\033[2m[%s]: message deleted\033[0m", when, name)
+	case msg.Type == protocol.TypeAction:
+		return fmt.Sprintf("%s\033[35m* %s %s\033[0m%s", when, name, highlightMentions(session.username, msg), editedMark(msg))
+	default:
+		return fmt.Sprintf("%s\033[33m[%s]\033[0m: %s%s", when, name, highlightMentions(session.username, msg), editedMark(msg))
+	}
+}
+
+// displayQuote shows the message a reply answers above the reply
+func displayQuote(session *chatSession, msg *protocol.WireMessage) {
+	q := msg.Quote
+	if q == nil {
+		return
+	}
+	name := q.Username
+	if name == session.username {
+		name = "You"
+	}
+	body := q.Body
+	if q.Deleted {
+		body = "message deleted"
+	}
+	fmt.Printf("   \033[2m╭─ %s #%d: %s\033[0m\n", name, q.Seq, body)
+}
+
+// reactionSummary formats counts like "👍 2  🎉 1", highlighting the
+// emoji we reacted with ourselves
+func reactionSummary(session *chatSession, reactions []protocol.Reaction) string {
+	parts := make([]string, 0, len(reactions))
+	for _, r := range reactions {
+		part := fmt.Sprintf("%s %d", r.Emoji, r.Count)
+		if slices.Contains(r.Users, session.username) {
+			part = "\033[36m" + part + "\033[0m"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// displayReactions shows a message's reactions under it
+func displayReactions(session *chatSession, msg *protocol.WireMessage) {
+	if len(msg.Reactions) == 0 {
+		return
+	}
+	fmt.Printf("   %s\n", reactionSummary(session, msg.Reactions))
+}
+
+// displayReactionUpdate shows the new reaction counts of a message that
+// has already scrolled past
+func displayReactionUpdate(session *chatSession, msg *protocol.WireMessage) {
+	if len(msg.Reactions) == 0 {
+		fmt.Printf("   \033[2m↳ #%d: no reactions\033[0m\n", msg.Seq)
+		return
+	}
+	fmt.Printf("   \033[2m↳ #%d:\033[0m %s\n", msg.Seq, reactionSummary(session, msg.Reactions))
+}
+
+// displayThread renders a thread: its first message, then the replies
+// indented, each quoting its parent unless that is the first message
+func displayThread(session *chatSession, msg *protocol.WireMessage) {
+	if len(msg.History) == 0 {
+		fmt.Println("🧵 Thread not found.")
+		return
+	}
+	fmt.Printf("🧵 ── thread, %d repl(ies) ──\n", len(msg.History)-1)
+	for _, m := range msg.History {
+		if m.MsgID == msg.ThreadID {
+			fmt.Println(historyLine(session, &m))
+			displayReactions(session, &m)
+			continue
+		}
+		if m.ParentID != msg.ThreadID {
+			fmt.Print("   ")
+			displayQuote(session, &m)
+		}
+		fmt.Println("   ↳ " + historyLine(session, &m))
+		if len(m.Reactions) > 0 {
+			fmt.Print("   ")
+			displayReactions(session, &m)
+		}
+	}
+	fmt.Println("🧵 ── end of thread ──")
+}
+
+// displayMissed renders messages that arrived while reconnecting
+func displayMissed(session *chatSession, missed []protocol.WireMessage) {
+	if len(missed) == 0 {
+		return
+	}
+	fmt.Printf("📜 ── %d message(s) while you were away ──\n", len(missed))
+	for _, msg := range missed {
+		if msg.Type == protocol.TypeAction {
+			displayActionMessage(session, &msg)
+		} else {
+			displayChatMessage(session, &msg)
+		}
+	}
+}
+
+// displayGap renders the messages checkGap asked for; the rest of the
+// reply has already been shown live
+func displayGap(session *chatSession, history []protocol.WireMessage) {
+	gap := session.gap
+	session.gap = seqRange{}
+	fmt.Printf("📜 ── missed message(s) #%d-#%d ──\n", gap.from, gap.to)
+	for _, msg := range history {
+		if msg.Seq < gap.from || msg.Seq > gap.to {
+			continue
+		}
+		if msg.Type == protocol.TypeAction {
+			displayActionMessage(session, &msg)
+		} else {
+			displayChatMessage(session, &msg)
+		}
+	}
+	fmt.Println("📜 ── end of missed messages ──")
 }
 
 // displayUserList shows the list of users in the room
@@ -258,10 +755,9 @@ func handleAdvancedUserInput(ctx context.Context, session *chatSession, errChan
 				continue
 			}
 
-			// Send regular message
+			// Send regular message; a broken connection is the reader's to fix
 			if err := sendChatMessage(input, session); err != nil {
-				errChan <- fmt.Errorf("error sending message: %w", err)
-				return
+				fmt.Printf("❌ Message not sent: %v\n", err)
 			}
 		}
 	}
@@ -297,12 +793,45 @@ func handleChatCommand(input string, session *chatSession) error {
 			status = "enabled"
 		}
 		fmt.Printf("🕒 Timestamps %s\n", status)
+	case "/history":
+		limit := defaultHistoryLimit
+		if len(parts) > 1 {
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("usage: /history [n]")
+			}
+			limit = n
+		}
+		return requestHistory(session, limit)
 	case "/me":
 		if len(parts) > 1 {
 			action := strings.Join(parts[1:], " ")
 			return sendActionMessage(action, session)
 		}
 		fmt.Println("Usage: /me <action>")
+	case "/edit":
+		return editMessage(parts[1:], session)
+	case "/delete":
+		return deleteMessage(parts[1:], session)
+	case "/reply":
+		if len(parts) < 3 {
+			return fmt.Errorf("usage: /reply <#n> <text>")
+		}
+		return sendReply(parts[1], strings.Join(parts[2:], " "), session)
+	case "/thread":
+		if len(parts) != 2 {
+			return fmt.Errorf("usage: /thread <#n>")
+		}
+		return requestThread(parts[1], session)
+	case "/react", "/unreact":
+		if len(parts) != 3 {
+			return fmt.Errorf("usage: %s <#n> <emoji or :shortcode:>", command)
+		}
+		msgType := protocol.TypeReact
+		if command == "/unreact" {
+			msgType = protocol.TypeUnreact
+		}
+		return sendReaction(msgType, parts[1], parts[2], session)
 	default:
 		fmt.Printf("❓ Unknown command: %s. Type /help for available commands.\n", command)
 	}
@@ -318,7 +847,14 @@ func printHelp() {
 	fmt.Println("║ /users     - List users in room      ║")
 	fmt.Println("║ /stats     - Show session stats      ║")
 	fmt.Println("║ /time      - Toggle timestamps       ║")
+	fmt.Println("║ /history [n] - Show recent messages  ║")
 	fmt.Println("║ /me <text> - Send action message     ║")
+	fmt.Println("║ /edit [#n] <text> - Edit a message   ║")
+	fmt.Println("║ /delete [#n] - Delete a message      ║")
+	fmt.Println("║ /reply <#n> <text> - Reply in thread ║")
+	fmt.Println("║ /thread <#n> - Show a whole thread   ║")
+	fmt.Println("║ /react <#n> :+1: - React to a msg    ║")
+	fmt.Println("║ /unreact <#n> :+1: - Undo reaction   ║")
 	fmt.Println("║ /clear     - Clear screen            ║")
 	fmt.Println("║ /quit      - Exit chat               ║")
 	fmt.Println("╚══════════════════════════════════════╝")
@@ -330,6 +866,7 @@ func printStats(session *chatSession) {
 	fmt.Println("📊 Session Statistics:")
 	fmt.Printf("   Room: %s\n", session.roomName)
 	fmt.Printf("   Connected for: %v\n", duration.Round(time.Second))
+	fmt.Printf("   Users online: %d\n", session.userCount)
 	fmt.Printf("   Messages received: %d\n", session.messageCount)
 	fmt.Printf("   Started: %s\n", session.startTime.Format("2006-01-02 15:04:05"))
 	requestUserList(session)
@@ -342,24 +879,109 @@ func clearScreen() {
 
 // sendChatMessage sends a regular chat message
 func sendChatMessage(text string, session *chatSession) error {
+	if err := session.checkBody(text); err != nil {
+		return err
+	}
 	msg := protocol.WireMessage{
 		Type:     protocol.TypeRoomMsg,
 		Room:     session.roomName,
 		Body:     text,
 		Username: session.username,
 	}
-	return session.enc.Encode(msg)
+	return session.send(&msg)
 }
 
 // sendActionMessage sends an action message (/me command)
 func sendActionMessage(action string, session *chatSession) error {
+	if err := session.checkBody(action); err != nil {
+		return err
+	}
 	msg := protocol.WireMessage{
 		Type:     protocol.TypeAction,
 		Room:     session.roomName,
 		Body:     action,
 		Username: session.username,
 	}
-	return session.enc.Encode(msg)
+	return session.send(&msg)
+}
+
+// sendReply sends text as a reply to the message ref points at
+func sendReply(ref, text string, session *chatSession) error {
+	parent, err := session.resolve(ref)
+	if err != nil {
+		return err
+	}
+	if err := session.checkBody(text); err != nil {
+		return err
+	}
+	return session.send(&protocol.WireMessage{
+		Type:     protocol.TypeRoomMsg,
+		Room:     session.roomName,
+		Body:     text,
+		Username: session.username,
+		ParentID: parent,
+	})
+}
+
+// requestThread asks for the thread the message ref points at belongs to
+func requestThread(ref string, session *chatSession) error {
+	id, err := session.resolve(ref)
+	if err != nil {
+		return err
+	}
+	return session.send(protocol.NewThreadRequest(session.roomName, id))
+}
+
+// sendReaction adds (react) or removes (unreact) our emoji on the message
+// ref points at
+func sendReaction(msgType, ref, emoji string, session *chatSession) error {
+	id, err := session.resolve(ref)
+	if err != nil {
+		return err
+	}
+	return session.send(&protocol.WireMessage{
+		Type:  msgType,
+		Room:  session.roomName,
+		MsgID: id,
+		Emoji: emoji,
+	})
+}
+
+// editMessage asks the server to replace the body of one of our messages
+func editMessage(args []string, session *chatSession) error {
+	id, rest, err := session.target(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("usage: /edit [#n] <new text>")
+	}
+	text := strings.Join(rest, " ")
+	if err := session.checkBody(text); err != nil {
+		return err
+	}
+	return session.send(&protocol.WireMessage{
+		Type:  protocol.TypeEdit,
+		Room:  session.roomName,
+		MsgID: id,
+		Body:  text,
+	})
+}
+
+// deleteMessage asks the server to retract one of our messages
+func deleteMessage(args []string, session *chatSession) error {
+	id, rest, err := session.target(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("usage: /delete [#n]")
+	}
+	return session.send(&protocol.WireMessage{
+		Type:  protocol.TypeDelete,
+		Room:  session.roomName,
+		MsgID: id,
+	})
 }
 
 // requestUserList requests the list of users in the room
@@ -369,7 +991,12 @@ func requestUserList(session *chatSession) error {
 		Room:     session.roomName,
 		Username: session.username,
 	}
-	return session.enc.Encode(msg)
+	return session.send(&msg)
+}
+
+// requestHistory asks the server for the room's recent messages
+func requestHistory(session *chatSession, limit int) error {
+	return session.send(protocol.NewHistoryRequest(session.roomName, limit))
 }
 
 // sendLeaveMessage notifies the server that the user is leaving
@@ -379,9 +1006,10 @@ func sendLeaveMessage(session *chatSession) {
 		Room:     session.roomName,
 		Username: session.username,
 	}
-	session.enc.Encode(msg) // Ignore error on shutdown
+	session.send(&msg) // Ignore error on shutdown
 }
 
 func init() {
+	roomsJoinCmd.Flags().IntVar(&joinHistory, "history", defaultHistoryLimit, "number of recent messages to show on join (0 to skip)")
 	roomsCmd.AddCommand(roomsJoinCmd)
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/danieljhkim/chat-cli/internal/config"
+	"github.com/danieljhkim/chat-cli/internal/net"
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+var mentionsCmd = &cobra.Command{
+	Use:   "mentions",
+	Short: "List unread @mentions across rooms",
+	Long: `List the room messages that @mentioned you while you were offline or
+not in the room. Listing them marks them as read.`,
+	Example: "chat-cli mentions",
+	Args:    cobra.NoArgs,
+	RunE:    runMentionsCommand,
+}
+
+func runMentionsCommand(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Get()
+	if err != nil {
+		return err
+	}
+	sess, err := dialServer(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer sess.Close()
+
+	mentions, err := requestMentions(sess)
+	if err != nil {
+		return err
+	}
+	displayMentions(cfg.Username, mentions)
+	return nil
+}
+
+// requestMentions fetches, and thereby clears, our unread mentions
+func requestMentions(sess *net.Session) ([]protocol.WireMessage, error) {
+	resp, err := sess.Request(&protocol.WireMessage{Type: protocol.TypeListMentions})
+	if err != nil {
+		return nil, err
+	}
+	switch resp.Type {
+	case protocol.TypeMentionList:
+		return resp.History, nil
+	case protocol.TypeError:
+		return nil, net.ResponseError(resp)
+	default:
+		return nil, fmt.Errorf("unexpected response type: %s", resp.Type)
+	}
+}
+
+func displayMentions(username string, mentions []protocol.WireMessage) {
+	if len(mentions) == 0 {
+		fmt.Println("No unread mentions.")
+		return
+	}
+
+	fmt.Printf("\nTotal: %d mention(s)\n", len(mentions))
+	for _, msg := range mentions {
+		body := highlightMentions(username, &msg)
+		if body == "" {
+			body = "\033[2m(no longer in the room's history)\033[0m"
+		}
+		who := msg.Username + ":"
+		if msg.Type == protocol.TypeAction {
+			who = "* " + msg.Username
+		}
+		fmt.Printf("  [%s] #%s %s%s %s%s\n", msg.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			msg.Room, seqLabel(&msg), who, body, editedMark(&msg))
+	}
+}
+
+// seqLabel is "#<seq> " for messages that have one, for `/reply` and
+// `/thread` inside the room
+func seqLabel(msg *protocol.WireMessage) string {
+	if msg.Seq == 0 {
+		return ""
+	}
+	return fmt.Sprintf("#%d ", msg.Seq)
+}
+
+func init() {
+	rootCmd.AddCommand(mentionsCmd)
+}
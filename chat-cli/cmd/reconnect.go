@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/danieljhkim/chat-cli/internal/net"
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+)
+
+// Reconnect backoff: 1s, 2s, 4s, ... capped at reconnectMaxDelay
+const (
+	reconnectBaseDelay   = time.Second
+	reconnectMaxDelay    = 30 * time.Second
+	maxReconnectAttempts = 10
+)
+
+// fatalReconnectCodes are server errors that retrying will not fix
+var fatalReconnectCodes = map[string]bool{
+	protocol.ErrCodeAuthFailed:         true,
+	protocol.ErrCodeUnsupportedVersion: true,
+	protocol.ErrCodeBanned:             true,
+}
+
+// reconnect redials with exponential backoff, resumes the server-side
+// session and re-joins the room from the last message seen. After a
+// server_shutdown it first waits as long as the server asked. It returns an
+// error once it gives up.
+func (s *chatSession) reconnect(ctx context.Context) error {
+	resumeToken := s.current().ResumeToken
+	s.swap(nil)
+
+	// with no message seen there is no seq to resume from, so anything
+	// posted during the outage is fetched as recent history instead
+	since, limit := s.latest(), 0
+	if since == 0 {
+		limit = defaultHistoryLimit
+	}
+
+	reason, delay := "Connection lost", reconnectBaseDelay
+	if s.retryIn > 0 {
+		reason, delay = "Server restarting", s.retryIn
+		s.retryIn = 0
+	}
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		printStatus(fmt.Sprintf("🔄 %s. Reconnecting in %s (attempt %d/%d)...", reason, delay, attempt, maxReconnectAttempts))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		printStatus(fmt.Sprintf("🔄 Reconnecting to %s (attempt %d/%d)...", s.roomName, attempt, maxReconnectAttempts))
+
+		sess, err := connectAndJoinRoom(s.roomName, limit, resumeToken, since)
+		if err == nil {
+			s.swap(sess)
+			printStatus("")
+			fmt.Printf("✅ Reconnected to %s\n", s.roomName)
+			return nil
+		}
+		var serr *net.ServerError
+		if errors.As(err, &serr) && fatalReconnectCodes[serr.Code] {
+			printStatus("")
+			return err
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+	printStatus("")
+	return fmt.Errorf("gave up after %d attempts: %w", maxReconnectAttempts, net.ErrConnectionLost)
+}
+
+// printStatus overwrites the current terminal line with text; "" clears it
+func printStatus(text string) {
+	fmt.Print("\r\033[K" + text)
+}
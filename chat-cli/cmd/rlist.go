@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 
 	"github.com/danieljhkim/chat-cli/internal/config"
@@ -37,44 +36,27 @@ func runListCommand(cmd *cobra.Command, args []string) error {
 
 // fetchRoomsList connects to server and retrieves rooms list
 func fetchRoomsList(cfg *config.Config) ([]string, error) {
-	conn, err := net.Connect(cfg.ServerAddress)
+	sess, err := dialServer(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to server: %w", err)
 	}
-	defer conn.Close()
+	defer sess.Close()
 
-	enc := json.NewEncoder(conn)
-	dec := json.NewDecoder(conn)
-
-	if err := sendListRequest(enc, cfg.Username); err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-
-	rooms, err := receiveRoomsResponse(dec)
-	if err != nil {
-		return nil, fmt.Errorf("failed to receive response: %w", err)
-	}
-
-	return rooms, nil
+	return requestRoomsList(sess, cfg.Username)
 }
 
-// sendListRequest sends the list rooms request to the server
-func sendListRequest(enc *json.Encoder, username string) error {
+// requestRoomsList sends the list rooms request and validates the reply
+func requestRoomsList(sess *net.Session, username string) ([]string, error) {
 	req := protocol.WireMessage{
 		Type:     protocol.TypeListRooms,
 		Username: username,
 	}
-	return enc.Encode(req)
-}
-
-// receiveRoomsResponse receives and validates the server response
-func receiveRoomsResponse(dec *json.Decoder) ([]string, error) {
-	var resp protocol.WireMessage
-	if err := dec.Decode(&resp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	resp, err := sess.Request(&req)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := validateResponse(&resp); err != nil {
+	if err := validateResponse(resp); err != nil {
 		return nil, err
 	}
 
@@ -87,7 +69,7 @@ func validateResponse(resp *protocol.WireMessage) error {
 	case protocol.TypeRoomsList:
 		return nil
 	case protocol.TypeError:
-		return fmt.Errorf("server error: %s", resp.Message)
+		return net.ResponseError(resp)
 	default:
 		return fmt.Errorf("unexpected response type: %s", resp.Type)
 	}
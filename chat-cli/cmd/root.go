@@ -4,9 +4,7 @@ Copyright © 2025 Daniel Kim
 package cmd
 
 import (
-	"fmt"
-	"os"
-
+	"github.com/danieljhkim/chat-cli/internal/net"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -26,15 +24,17 @@ Example usage:
   chat-cli init,
   chat-cli rooms list
   chat-cli rooms join general`,
-	Version: "1.0.0",
+	Version: net.ClientVersion,
+	// errors are printed by Exit with a hint and a matching exit code
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		Exit(err)
 	}
 }
 
@@ -4,7 +4,6 @@ Copyright © 2025 Daniel Kim
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -49,12 +48,14 @@ func init() {
 // sendDirectMessage handles connecting to the server and sending a DM
 func sendDirectMessage(targetUser, messageContent string) error {
 	currentUser := cfg.Username
-	conn, err := net.Connect(cfg.ServerAddress)
+	sess, err := dialServer(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to connect to server: %w", err)
 	}
-	defer conn.Close()
-	enc := json.NewEncoder(conn)
+	defer sess.Close()
+	if err := sess.CheckBody(messageContent); err != nil {
+		return err
+	}
 
 	dmMsg := protocol.WireMessage{
 		Type:      protocol.TypeSendDM,
@@ -64,8 +65,20 @@ func sendDirectMessage(targetUser, messageContent string) error {
 		Timestamp: time.Now(),
 	}
 
-	if err := enc.Encode(dmMsg); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	resp, err := sess.Request(&dmMsg)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.Type == protocol.TypeError:
+		return net.ResponseError(resp)
+	case resp.Message == "queued":
+		fmt.Printf("📬 %s is offline; message queued for delivery\n", targetUser)
+	default:
+		fmt.Printf("✅ Message delivered to %s\n", targetUser)
+	}
+	if resp.MsgID != "" {
+		fmt.Printf("   id %s (for `chat-cli dm edit` or `dm delete`)\n", resp.MsgID)
 	}
 	return nil
 }
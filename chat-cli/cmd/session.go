@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/danieljhkim/chat-cli/internal/config"
+	"github.com/danieljhkim/chat-cli/internal/net"
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+)
+
+// dialServer logs in and prints DMs and notices that arrive while a
+// command waits for its reply.
+func dialServer(cfg *config.Config) (*net.Session, error) {
+	return resumeServer(cfg, "")
+}
+
+// resumeServer is dialServer after a dropped connection; see net.Resume.
+func resumeServer(cfg *config.Config, resumeToken string) (*net.Session, error) {
+	sess, err := net.Resume(cfg, resumeToken)
+	if err != nil {
+		return nil, err
+	}
+	sess.Notify = printNotice
+	return sess, nil
+}
+
+// printNotice renders server pushes that aren't replies to a request.
+func printNotice(msg *protocol.WireMessage) {
+	switch msg.Type {
+	case protocol.TypeDM, protocol.TypeMessageEdited:
+		displayDirectMessage(msg)
+	case protocol.TypeMessageDeleted:
+		fmt.Printf("💌 %s deleted a direct message they sent you\n", msg.Username)
+	case protocol.TypeInfo:
+		fmt.Printf("ℹ️  %s\n", msg.Message)
+	case protocol.TypeWarning:
+		fmt.Printf("⚠️  %s\n", msg.Message)
+	case protocol.TypeServerShutdown:
+		fmt.Printf("🛑 %s\n", msg.Message)
+	}
+}
+
+// stamp formats the server-assigned timestamp and, for room messages, the
+// sequence number of msg
+func stamp(msg *protocol.WireMessage, layout string) string {
+	ts := msg.Timestamp
+	if ts.IsZero() {
+		ts = time.Now() // older servers may not set it
+	}
+	label := ts.Local().Format(layout)
+	if msg.Seq > 0 {
+		label += fmt.Sprintf(" #%d", msg.Seq)
+	}
+	return "[" + label + "] "
+}
+
+// displayDirectMessage shows a DM pushed by the server, or a new version
+// of one that was edited
+func displayDirectMessage(msg *protocol.WireMessage) {
+	fmt.Printf("💌 %s\033[35m[DM from %s]\033[0m: %s%s\n", stamp(msg, "15:04:05"), msg.Username, msg.Body, editedMark(msg))
+}
@@ -21,9 +21,22 @@ var (
 	loadErr      error
 )
 
+// TLS modes for server_address
+const (
+	TLSOff    = "off"    // plain TCP
+	TLSVerify = "verify" // verify the chain against system roots or tls_ca_file
+	TLSTOFU   = "tofu"   // pin the server certificate seen on first connect
+)
+
 type Config struct {
 	ServerAddress string `yaml:"server_address"`
 	Username      string `yaml:"username"`
+	Token         string `yaml:"token,omitempty"` // session token issued at login
+
+	TLSMode        string `yaml:"tls_mode,omitempty"`        // off, verify or tofu
+	TLSCAFile      string `yaml:"tls_ca_file,omitempty"`     // custom CA bundle (PEM)
+	TLSServerName  string `yaml:"tls_server_name,omitempty"` // overrides the host in server_address
+	TLSFingerprint string `yaml:"tls_fingerprint,omitempty"` // pinned SHA-256 of the server certificate
 }
 
 func (c *Config) Validate() error {
@@ -33,9 +46,19 @@ func (c *Config) Validate() error {
 	if strings.TrimSpace(c.Username) == "" {
 		return fmt.Errorf("username cannot be empty")
 	}
+	switch c.TLSMode {
+	case "", TLSOff, TLSVerify, TLSTOFU:
+	default:
+		return fmt.Errorf("unknown tls mode %q (want off, verify or tofu)", c.TLSMode)
+	}
 	return nil
 }
 
+// TLSEnabled reports whether connections should use TLS.
+func (c *Config) TLSEnabled() bool {
+	return c.TLSMode == TLSVerify || c.TLSMode == TLSTOFU
+}
+
 func GetConfigPath() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
@@ -49,15 +72,20 @@ func Save(cfg *Config, path string) error {
 		return fmt.Errorf("config validation failed: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	// the file holds the session token, so keep it to the user
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	file, err := os.Create(path)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to create config file: %w", err)
 	}
 	defer file.Close()
+	// tighten files written by versions that used 0644
+	if err := file.Chmod(0o600); err != nil {
+		return fmt.Errorf("failed to restrict config file permissions: %w", err)
+	}
 
 	encoder := yaml.NewEncoder(file)
 	defer encoder.Close()
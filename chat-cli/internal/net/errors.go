@@ -0,0 +1,29 @@
+package net
+
+import (
+	"fmt"
+
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+)
+
+// ServerError is an error response from the server.
+type ServerError struct {
+	Code    string // one of protocol.ErrCode*, empty from older servers
+	Message string // the server's human-readable text
+}
+
+func (e *ServerError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("server error: %s", e.Message)
+	}
+	return fmt.Sprintf("server error (%s): %s", e.Code, e.Message)
+}
+
+// ResponseError returns resp as a *ServerError if it is an error response,
+// or nil otherwise.
+func ResponseError(resp *protocol.WireMessage) error {
+	if resp.Type != protocol.TypeError {
+		return nil
+	}
+	return &ServerError{Code: resp.Code, Message: resp.Message}
+}
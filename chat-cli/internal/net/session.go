@@ -0,0 +1,231 @@
+package net
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/danieljhkim/chat-cli/internal/config"
+	"github.com/danieljhkim/chat-cli/internal/protocol"
+)
+
+// Client identification sent in hello
+const (
+	ClientName    = "chat-cli"
+	ClientVersion = "1.0.0"
+)
+
+// clientCapabilities lists the optional features chat-cli understands
+var clientCapabilities = []string{protocol.CapHistory, protocol.CapAcks}
+
+// ErrConnectionLost is returned by Receive when the server closed the
+// connection or stopped answering.
+var ErrConnectionLost = errors.New("connection lost")
+
+// defaultIdleTimeout applies when the server does not advertise a ping interval.
+const defaultIdleTimeout = 60 * time.Second
+
+// Session is an authenticated connection to the chat server.
+type Session struct {
+	Conn    net.Conn
+	Enc     *json.Encoder
+	Dec     *json.Decoder
+	Welcome *protocol.WireMessage // server version, negotiated capabilities and limits
+
+	// ResumeToken from auth_ok; pass it to Resume after the connection drops.
+	ResumeToken string
+
+	// Notify, when set, receives frames skipped by Request (live DMs, notices).
+	Notify func(msg *protocol.WireMessage)
+
+	mu     sync.Mutex // serializes Enc and nextID
+	nextID uint64
+}
+
+// Dial connects to the server and logs in with the token saved by `chat-cli init`.
+func Dial(cfg *config.Config) (*Session, error) {
+	return Resume(cfg, "")
+}
+
+// Resume is Dial for a reconnect: the server puts the session back in the
+// rooms the dropped connection with resumeToken was in.
+func Resume(cfg *config.Config, resumeToken string) (*Session, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("not logged in: run `chat-cli init` first")
+	}
+	s, err := open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	login := protocol.NewLoginMessage(cfg.Username, "", cfg.Token)
+	login.ResumeToken = resumeToken
+	if _, err := s.auth(login); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Authenticate registers or logs in with a password and returns the session
+// token issued by the server.
+func Authenticate(cfg *config.Config, password string, register bool) (string, error) {
+	s, err := open(cfg)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+
+	req := protocol.NewLoginMessage(cfg.Username, password, "")
+	if register {
+		req = protocol.NewRegisterMessage(cfg.Username, password)
+	}
+	return s.auth(req)
+}
+
+// Send assigns msg a fresh request ID, writes it and returns the ID. It is
+// safe to call from multiple goroutines.
+func (s *Session) Send(msg *protocol.WireMessage) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	msg.ID = strconv.FormatUint(s.nextID, 10)
+	if err := s.Enc.Encode(msg); err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// Request sends msg and reads frames until the server's reply to it arrives.
+// Unrelated frames are passed to Notify. The reply may be an error; an error
+// not tied to any request (the server dropping the connection) also ends
+// the wait.
+func (s *Session) Request(msg *protocol.WireMessage) (*protocol.WireMessage, error) {
+	id, err := s.Send(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send %s request: %w", msg.Type, err)
+	}
+	for {
+		resp, err := s.Receive()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s response: %w", msg.Type, err)
+		}
+		if resp.ReplyTo == id || (resp.Type == protocol.TypeError && resp.ReplyTo == "") {
+			return resp, nil
+		}
+		if s.Notify != nil {
+			s.Notify(resp)
+		}
+	}
+}
+
+// Receive reads the next frame, answering server pings along the way. If
+// nothing arrives for twice the server's ping interval the server is
+// presumed dead and ErrConnectionLost is returned.
+func (s *Session) Receive() (*protocol.WireMessage, error) {
+	for {
+		_ = s.Conn.SetReadDeadline(time.Now().Add(s.idleTimeout()))
+		var msg protocol.WireMessage
+		if err := s.Dec.Decode(&msg); err != nil {
+			// a bad frame is the server's fault; anything else (EOF, a
+			// reset, a timeout, a frame cut off mid-way) is the connection
+			var serr *json.SyntaxError
+			var terr *json.UnmarshalTypeError
+			if errors.As(err, &serr) || errors.As(err, &terr) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: %v", ErrConnectionLost, err)
+		}
+		if msg.Type == protocol.TypePing {
+			if _, err := s.Send(protocol.NewPongMessage()); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrConnectionLost, err)
+			}
+			continue
+		}
+		return &msg, nil
+	}
+}
+
+// idleTimeout is how long Receive waits before giving up on the server.
+func (s *Session) idleTimeout() time.Duration {
+	if secs := s.Limit(protocol.LimitPingInterval); secs > 0 {
+		return 2*time.Duration(secs)*time.Second + 5*time.Second
+	}
+	return defaultIdleTimeout
+}
+
+func (s *Session) Close() error {
+	return s.Conn.Close()
+}
+
+func open(cfg *config.Config) (*Session, error) {
+	conn, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		Conn: conn,
+		Enc:  json.NewEncoder(conn),
+		Dec:  json.NewDecoder(conn),
+	}
+	if err := s.handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// handshake exchanges hello/welcome before anything else is sent.
+func (s *Session) handshake() error {
+	hello := protocol.NewHelloMessage(ClientName+"/"+ClientVersion, clientCapabilities)
+	resp, err := s.Request(hello)
+	if err != nil {
+		return err
+	}
+	switch resp.Type {
+	case protocol.TypeWelcome:
+		s.Welcome = resp
+		return nil
+	case protocol.TypeError:
+		return fmt.Errorf("server rejected connection: %w", ResponseError(resp))
+	default:
+		return fmt.Errorf("unexpected handshake response type: %s", resp.Type)
+	}
+}
+
+// Limit returns a server limit from welcome, or 0 when not advertised.
+func (s *Session) Limit(key string) int {
+	if s.Welcome == nil {
+		return 0
+	}
+	return s.Welcome.Limits[key]
+}
+
+// CheckBody returns an error when body is over the server's
+// max_body_bytes, so it can be reported before the server rejects it.
+func (s *Session) CheckBody(body string) error {
+	if limit := s.Limit(protocol.LimitMaxBodyBytes); limit > 0 && len(body) > limit {
+		return fmt.Errorf("message is %d bytes; the server accepts at most %d", len(body), limit)
+	}
+	return nil
+}
+
+// auth sends a register/login request and waits for the server's verdict.
+func (s *Session) auth(req *protocol.WireMessage) (string, error) {
+	resp, err := s.Request(req)
+	if err != nil {
+		return "", err
+	}
+	switch resp.Type {
+	case protocol.TypeAuthOK:
+		s.ResumeToken = resp.ResumeToken
+		return resp.Token, nil
+	case protocol.TypeError:
+		return "", fmt.Errorf("%s failed: %w", req.Type, ResponseError(resp))
+	default:
+		return "", fmt.Errorf("unexpected %s response type: %s", req.Type, resp.Type)
+	}
+}
@@ -1,15 +1,103 @@
 package net
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
+	"os"
+	"strings"
+
+	"github.com/danieljhkim/chat-cli/internal/config"
 )
 
-// creates a TCP connection to the server
-func Connect(serverAddress string) (net.Conn, error) {
-	conn, err := net.Dial("tcp", serverAddress)
+// creates a TCP (or TLS, per cfg.TLSMode) connection to the server
+func Connect(cfg *config.Config) (net.Conn, error) {
+	if !cfg.TLSEnabled() {
+		conn, err := net.Dial("tcp", cfg.ServerAddress)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to %s: %w", cfg.ServerAddress, err)
+		}
+		return conn, nil
+	}
+
+	tlsCfg, err := clientTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := tls.Dial("tcp", cfg.ServerAddress, tlsCfg)
 	if err != nil {
-		return nil, fmt.Errorf("unable to connect to %s: %w", serverAddress, err)
+		return nil, fmt.Errorf("unable to connect to %s: %w", cfg.ServerAddress, err)
+	}
+
+	// trust on first use: remember the certificate we just accepted
+	if cfg.TLSMode == config.TLSTOFU && cfg.TLSFingerprint == "" {
+		fp := Fingerprint(conn.ConnectionState().PeerCertificates[0])
+		cfg.TLSFingerprint = fp
+		if err := config.Set(cfg); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to pin server certificate: %w", err)
+		}
+		fmt.Printf("🔐 Trusting server certificate %s (pinned in config)\n", fp)
 	}
 	return conn, nil
 }
+
+// Fingerprint returns the hex SHA-256 of a certificate's DER bytes.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func clientTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	serverName := cfg.TLSServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(cfg.ServerAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server address %q: %w", cfg.ServerAddress, err)
+		}
+		serverName = host
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.TLSMode == config.TLSTOFU {
+		// the pin replaces chain verification, so self-signed servers work
+		tlsCfg.InsecureSkipVerify = true
+	}
+	if cfg.TLSMode == config.TLSTOFU || cfg.TLSFingerprint != "" {
+		pinned := strings.ToLower(strings.ReplaceAll(cfg.TLSFingerprint, ":", ""))
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("server sent no certificate")
+			}
+			if pinned == "" {
+				return nil // first use, pinned by Connect
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if got := hex.EncodeToString(sum[:]); got != pinned {
+				return fmt.Errorf("server certificate fingerprint %s does not match pinned %s", got, pinned)
+			}
+			return nil
+		}
+	}
+	return tlsCfg, nil
+}
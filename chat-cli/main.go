@@ -14,12 +14,17 @@ func main() {
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Println("Configuration file not found or invalid.")
-		cmd.PromptInitAndSave()
+		if err := cmd.PromptInitAndSave(); err != nil {
+			cmd.Exit(err)
+		}
 		return
 	}
 	fmt.Println("=== Chat-CLI Configuration ===")
 	fmt.Printf("Server Address: %q\n", cfg.ServerAddress)
 	fmt.Printf("Username: %q\n", cfg.Username)
+	if cfg.TLSEnabled() {
+		fmt.Printf("TLS: %s\n", cfg.TLSMode)
+	}
 	fmt.Println("==============================")
 	fmt.Println()
 	cmd.Execute()
@@ -7,6 +7,7 @@ import (
 	"syscall"
 
 	"github.com/danieljhkim/chat-server/internal/app"
+	"github.com/danieljhkim/chat-server/internal/chatstore"
 	"github.com/danieljhkim/chat-server/internal/config"
 	"github.com/danieljhkim/chat-server/internal/logger"
 	netutil "github.com/danieljhkim/chat-server/internal/net"
@@ -20,35 +21,94 @@ func main() {
 		panic("failed to load config: " + err.Error())
 	}
 
+	// Listeners and storage only read these settings here, at startup.
+	cfg := config.Get()
+
 	// 2) Initialise a structured logger at the configured level.
-	log := logger.New(config.Cfg.LogLevel)
+	log := logger.New(cfg.LogLevel)
 	log.Info("configuration loaded",
-		"listen_address", config.Cfg.ListenAddress,
-		"transport", config.Cfg.Transport,
-		"max_message_bytes", config.Cfg.MaxMessageBytes,
+		"listen_address", cfg.ListenAddress,
+		"transport", cfg.Transport,
+		"max_message_bytes", cfg.MaxMessageBytes,
+		"tls", cfg.TLSEnabled(),
+		"storage", cfg.Storage.Driver,
+		"send_queue_policy", cfg.SendQueue.Policy,
 	)
 
-	// 3) Graceful-shutdown context (Ctrl-C → cancel).
+	// 3) Graceful-shutdown context (Ctrl-C → cancel). Listeners stop
+	// accepting, then the hub notifies and drains clients (see step 10).
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	// 4) Create and run the Hub (central router) in its own goroutine.
-	hub := app.NewHub(log)
-	go hub.Run(ctx)
+	// 4) Open the storage backend; Close flushes a final snapshot once the
+	// hub has stopped.
+	store, err := chatstore.Open(chatstore.Options{
+		Driver:           cfg.Storage.Driver,
+		Path:             cfg.Storage.Path,
+		SnapshotEvery:    cfg.Storage.SnapshotEvery,
+		Fsync:            cfg.Storage.Fsync,
+		RoomHistoryLimit: cfg.Storage.RoomHistoryLimit,
+	})
+	if err != nil {
+		log.Error("failed to open storage", "err", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := store.Close(); err != nil {
+			log.Error("failed to close storage", "err", err)
+		}
+	}()
+
+	// 5) Create and run the Hub (central router) in its own goroutine.
+	hub, err := app.NewHub(log, store)
+	if err != nil {
+		log.Error("failed to start hub", "err", err)
+		os.Exit(1)
+	}
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(hubDone)
+	}()
+
+	// 6) Reload the config on SIGHUP without dropping anyone.
+	go watchReload(ctx, hub, log)
+
+	// 7) Optionally serve WebSocket clients next to the TCP listener.
+	if cfg.Transport == "tcp" && cfg.WebSocketAddress != "" {
+		go func() {
+			if err := netutil.StartWebSocket(ctx, cfg.WebSocketAddress, hub, log); err != nil {
+				log.Error("websocket listener stopped", "err", err)
+			}
+		}()
+	}
+
+	// 8) Optionally serve expvar metrics such as per-client queue depth.
+	if cfg.MetricsAddress != "" {
+		go func() {
+			if err := netutil.StartMetrics(ctx, cfg.MetricsAddress, hub, log); err != nil {
+				log.Error("metrics listener stopped", "err", err)
+			}
+		}()
+	}
 
-	// 5) Start a network listener based on the configured transport.
+	// 9) Start a network listener based on the configured transport.
 	var listenErr error
-	switch config.Cfg.Transport {
+	switch cfg.Transport {
 	case "tcp":
-		listenErr = netutil.StartTCP(ctx, config.Cfg.ListenAddress, hub, log)
-	// case "websocket":
-	//     listenErr = netutil.StartWebSocket(ctx, config.Cfg.ListenAddress, hub, log)
+		listenErr = netutil.StartTCP(ctx, cfg.ListenAddress, hub, log)
+	case "websocket":
+		listenErr = netutil.StartWebSocket(ctx, cfg.ListenAddress, hub, log)
 	default:
-		log.Error("unknown transport", "transport", config.Cfg.Transport)
+		log.Error("unknown transport", "transport", cfg.Transport)
 		os.Exit(1)
 	}
 
 	if listenErr != nil {
 		log.Error("server stopped", "err", listenErr)
 	}
+
+	// 10) Let the hub notify and drain clients before storage is closed.
+	stop()
+	<-hubDone
 }
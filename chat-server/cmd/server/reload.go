@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/danieljhkim/chat-server/internal/app"
+	"github.com/danieljhkim/chat-server/internal/config"
+	"github.com/danieljhkim/chat-server/internal/logger"
+)
+
+// watchReload reloads the config on every SIGHUP until ctx is done.
+func watchReload(ctx context.Context, hub *app.Hub, log *slog.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reload(hub, log)
+		}
+	}
+}
+
+// reload validates and applies the config file, logging what changed and
+// what still needs a restart. An invalid file leaves everything as it was.
+func reload(hub *app.Hub, log *slog.Logger) {
+	changes, err := config.Reload()
+	if err != nil {
+		log.Error("config reload rejected; keeping the running config", "err", err)
+		return
+	}
+
+	var restart []string
+	for _, c := range changes {
+		if c.Restart {
+			restart = append(restart, c.Key)
+			log.Warn("config changed; restart to apply", "key", c.Key, "old", c.Old, "new", c.New)
+		} else {
+			log.Info("config changed", "key", c.Key, "old", c.Old, "new", c.New)
+		}
+	}
+
+	cfg := config.Get()
+	logger.SetLevel(cfg.LogLevel)
+	hub.ApplyConfig(cfg)
+	log.Info("config reloaded", "changed", len(changes), "needs_restart", restart)
+}
@@ -0,0 +1,171 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/config"
+	"github.com/danieljhkim/chat-server/internal/protocol"
+	"github.com/danieljhkim/chat-server/internal/security"
+)
+
+// isModerator reports whether username may edit and delete anyone's messages.
+func isModerator(username string) bool {
+	return slices.Contains(config.Get().Moderators, username)
+}
+
+// mayAmend reports whether username may edit or delete a message by author.
+func mayAmend(username, author string) bool {
+	return username == author || isModerator(username)
+}
+
+// amendVerb names what req does to a message, for logs and notices.
+func amendVerb(req protocol.WireMessage) string {
+	if req.Type == protocol.TypeEdit {
+		return "edited"
+	}
+	return "deleted"
+}
+
+// amendNotice describes an edit or delete of msg made by editor.
+func amendNotice(req, msg protocol.WireMessage, editor string) *protocol.WireMessage {
+	var notice *protocol.WireMessage
+	if req.Type == protocol.TypeEdit {
+		notice = protocol.NewMessageEditedNotification(msg.Room, msg.MsgID, msg.Username, msg.Body)
+	} else {
+		notice = protocol.NewMessageDeletedNotification(msg.Room, msg.MsgID, msg.Username)
+	}
+	notice.Seq = msg.Seq
+	notice.Target = msg.Target
+	notice.Mentions = msg.Mentions
+	if editor != msg.Username {
+		notice.Message = fmt.Sprintf("%s by moderator %s", amendVerb(req), editor)
+	}
+	return notice
+}
+
+// applyAmend returns msg as changed by the edit or delete request req.
+func applyAmend(req, msg protocol.WireMessage) protocol.WireMessage {
+	if req.Type == protocol.TypeEdit {
+		msg.Body = security.SanitizeInput(req.Body)
+		msg.Edited = true
+	} else {
+		msg.Body = ""
+		msg.Deleted = true
+		msg.Reactions = nil
+		msg.Mentions = nil
+	}
+	return msg
+}
+
+// amend edits or deletes one of the room's messages. Deleted messages stay
+// behind as tombstones so sequence numbers have no holes.
+func (r *Room) amend(c *Client, req protocol.WireMessage) {
+	msg, ok := r.lookup(req.MsgID)
+	if !ok || msg.Deleted {
+		c.ReplyError(req, protocol.ErrCodeMessageNotFound, fmt.Sprintf("no message %q in room %q", req.MsgID, r.Name))
+		return
+	}
+	if !mayAmend(c.Username, msg.Username) {
+		c.ReplyError(req, protocol.ErrCodeNotAuthorized, "you can only edit or delete your own messages")
+		return
+	}
+
+	mentioned := msg.Mentions
+	msg = applyAmend(req, msg)
+	if req.Type == protocol.TypeEdit {
+		msg.Mentions = r.parseMentions(msg.Body)
+	}
+	err := r.store.UpdateRoomMessage(roomMessageFromWire(msg))
+	if err != nil && !errors.Is(err, chatstore.ErrMessageNotFound) {
+		r.log.Error("update room message failed", "msg_id", msg.MsgID, "err", err)
+		c.ReplyError(req, protocol.ErrCodeInternal, "failed to update message")
+		return
+	}
+	r.history.replace(msg)
+	// only users the edit newly mentions are notified
+	r.recordMentions(msg, slices.DeleteFunc(slices.Clone(msg.Mentions), func(u string) bool {
+		return slices.Contains(mentioned, u)
+	}))
+	r.log.Info("message "+amendVerb(req), "msg_id", msg.MsgID, "author", msg.Username, "by", c.Username)
+
+	notice := amendNotice(req, msg, c.Username)
+	r.Broadcast(*notice, c)
+	c.Reply(req, *notice)
+}
+
+// lookup finds a message in scrollback, falling back to the store for
+// older ones.
+func (r *Room) lookup(id string) (protocol.WireMessage, bool) {
+	if msg, ok := r.history.find(id); ok {
+		return msg, true
+	}
+	m, err := r.store.GetRoomMessage(r.Name, id)
+	if err != nil {
+		if !errors.Is(err, chatstore.ErrMessageNotFound) {
+			r.log.Error("load room message failed", "msg_id", id, "err", err)
+		}
+		return protocol.WireMessage{}, false
+	}
+	return wireFromRoomMessage(m), true
+}
+
+// handleAmend routes edit/delete requests: room messages to their room,
+// DMs to amendDM.
+func (h *Hub) handleAmend(c *Client, msg protocol.WireMessage) {
+	if msg.Room == "" {
+		h.amendDM(c, msg)
+		return
+	}
+	if room, ok := h.memberRoom(c, msg); ok {
+		h.forward(room, opAmend, c, msg)
+	}
+}
+
+// amendDM edits or deletes a direct message, telling the recipient's
+// connected sessions. Deleted DMs are removed from the store.
+func (h *Hub) amendDM(c *Client, req protocol.WireMessage) {
+	dm, err := h.store.GetDM(req.MsgID)
+	if errors.Is(err, chatstore.ErrMessageNotFound) {
+		c.ReplyError(req, protocol.ErrCodeMessageNotFound, fmt.Sprintf("no direct message %q", req.MsgID))
+		return
+	}
+	if err != nil {
+		h.log.Error("load dm failed", "msg_id", req.MsgID, "err", err)
+		c.ReplyError(req, protocol.ErrCodeInternal, "failed to load direct message")
+		return
+	}
+	if !mayAmend(c.Username, dm.Sender) {
+		c.ReplyError(req, protocol.ErrCodeNotAuthorized, "you can only edit or delete your own messages")
+		return
+	}
+
+	msg := applyAmend(req, dmMessage(dm))
+	if req.Type == protocol.TypeEdit {
+		dm.Body, dm.Edited = msg.Body, true
+		err = h.store.UpdateDM(dm)
+	} else {
+		err = h.store.DeleteDM(dm.ID)
+	}
+	if err != nil {
+		h.log.Error("update dm failed", "msg_id", dm.ID, "err", err)
+		c.ReplyError(req, protocol.ErrCodeInternal, "failed to update direct message")
+		return
+	}
+	h.log.Info("dm "+amendVerb(req), "msg_id", dm.ID, "sender", dm.Sender, "by", c.Username)
+
+	notice := amendNotice(req, msg, c.Username)
+	if sessions := h.users[dm.Recipient]; len(sessions) > 0 {
+		frame, err := encodeFrame(*notice)
+		if err != nil {
+			h.log.Error("encode dm notice failed", "err", err)
+		} else {
+			for cl := range sessions {
+				cl.SendFrame(frame)
+			}
+		}
+	}
+	c.Reply(req, *notice)
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/danieljhkim/chat-server/internal/protocol"
+)
+
+// BenchmarkBroadcastEncoding compares delivering one room message to every
+// member when each client encodes its own copy (per-client) with encoding
+// once and sharing the frame (shared-frame, what Room.Broadcast does). Each
+// op queues the message for all members and then writes every queue out the
+// way WriteLoop does, so allocs/op and ns/op cover the whole path.
+func BenchmarkBroadcastEncoding(b *testing.B) {
+	msg := *protocol.NewRoomMessage("bench", "alice", strings.Repeat("lorem ipsum ", 20))
+	msg.Seq = 42
+
+	for _, members := range []int{10, 500, 5000} {
+		h := benchHub(b)
+		room := NewRoom("bench", 0, h.store, h.log)
+		for i := 0; i < members; i++ {
+			room.Add(benchMember(b, h))
+		}
+
+		b.Run(fmt.Sprintf("members=%d/per-client", members), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for m := range room.Members {
+					m.Send(msg)
+				}
+				flushMembers(b, room)
+			}
+		})
+		b.Run(fmt.Sprintf("members=%d/shared-frame", members), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				room.Broadcast(msg, nil)
+				flushMembers(b, room)
+			}
+		})
+	}
+}
+
+// benchMember is a client with no loops running, so its send queue is
+// only drained by flushMembers.
+func benchMember(b *testing.B, h *Hub) *Client {
+	b.Helper()
+	conn, peer := net.Pipe()
+	c := NewClient(context.Background(), conn, h, h.log)
+	b.Cleanup(func() {
+		c.Close()
+		peer.Close()
+	})
+	return c
+}
+
+// flushMembers writes out everything queued for the room's members.
+func flushMembers(b *testing.B, room *Room) {
+	for m := range room.Members {
+		for len(m.send) > 0 {
+			if err := writeOutbound(io.Discard, <-m.send); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
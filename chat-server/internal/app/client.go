@@ -1,76 +1,559 @@
 package app
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/danieljhkim/chat-server/internal/chatstore"
 	"github.com/danieljhkim/chat-server/internal/config"
 	"github.com/danieljhkim/chat-server/internal/protocol"
+	"github.com/danieljhkim/chat-server/internal/ratelimit"
+	"github.com/danieljhkim/chat-server/internal/security"
 )
 
+// serverAgent identifies this server in welcome messages
+const serverAgent = "chat-server/1.0.0"
+
+// serverCapabilities lists the optional features this server implements
+var serverCapabilities = []string{protocol.CapHistory, protocol.CapAcks}
+
+// outbound is one entry in a client's send queue. Broadcasts carry a frame
+// encoded once and shared by every recipient; anything else is encoded by
+// the client's WriteLoop.
+type outbound struct {
+	msg     protocol.WireMessage
+	frame   []byte        // read-only, shared across clients
+	written chan struct{} // if set, closed by WriteLoop once out is on the wire
+}
+
+// encodeFrame serializes msg into a newline-terminated wire frame.
+func encodeFrame(msg protocol.WireMessage) ([]byte, error) {
+	frame, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return append(frame, '\n'), nil
+}
+
+// writeOutbound puts out on w, encoding it first unless it already is a frame.
+func writeOutbound(w io.Writer, out outbound) error {
+	frame := out.frame
+	if frame == nil {
+		var err error
+		if frame, err = encodeFrame(out.msg); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(frame)
+	return err
+}
+
 type Client struct {
-	Username string
-	conn     net.Conn
-	hub      *Hub
-	send     chan protocol.WireMessage
-	log      *slog.Logger
+	Username  string // set by the hub once the connection has authenticated
+	conn      net.Conn
+	hub       *Hub
+	send      chan outbound // never closed; WriteLoop stops on ctx instead
+	state     atomic.Int32  // clientState, advanced by ReadLoop and Close
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	writeMu   sync.Mutex // serializes writes from WriteLoop and Kick
+	log       *slog.Logger
+
+	capabilities []string // negotiated in hello; set before any message reaches the hub
+
+	resumeToken string // issued by the hub at login; owned by the hub
+
+	policy      string        // slow-consumer policy, see config.Policy*
+	overflowMu  sync.Mutex    // guards overflow
+	overflow    []outbound    // spill policy: messages queued behind a full send
+	overflowLen atomic.Int64  // len(overflow), readable without the lock
+	dropped     atomic.Int64  // dropped since the client was last warned
+	dropTotal   atomic.Int64  // dropped over the connection's lifetime
+	evicted     chan struct{} // closed when the client is to be disconnected
+	evictOnce   sync.Once
+	evictCode   string // set before evicted is closed
+	evictReason string
+
+	ip       string
+	limiter  *ratelimit.Bucket  // per-connection limit, used by ReadLoop only
+	strikes  *ratelimit.Strikes // per-connection violations, used by ReadLoop only
+	oversize *ratelimit.Strikes // frames over max_message_bytes, used by ReadLoop only
 }
 
-func NewClient(conn net.Conn, hub *Hub, log *slog.Logger) *Client {
-	return &Client{
-		conn: conn,
-		hub:  hub,
-		send: make(chan protocol.WireMessage, 256),
-		log:  log.With("addr", conn.RemoteAddr()),
+// clientState is where a connection is in its lifecycle. It only moves
+// forward: connected → greeted → authenticated → closed, where closed can
+// follow any state.
+type clientState int32
+
+const (
+	stateConnected     clientState = iota // waiting for hello
+	stateGreeted                          // hello accepted, waiting for register/login
+	stateAuthenticated                    // logged in; messages go to the hub
+	stateClosed                           // Close was called; loops are exiting
+)
+
+// NewClient wraps conn. Cancelling ctx closes the client, as does Close;
+// the caller then starts ReadLoop and WriteLoop, and ReadLoop registers
+// the client with the hub.
+func NewClient(ctx context.Context, conn net.Conn, hub *Hub, log *slog.Logger) *Client {
+	c := &Client{
+		conn:     conn,
+		hub:      hub,
+		send:     make(chan outbound, config.Get().SendQueue.Size),
+		policy:   config.Get().SendQueue.Policy,
+		evicted:  make(chan struct{}),
+		log:      log.With("addr", conn.RemoteAddr()),
+		ip:       RemoteIP(conn.RemoteAddr()),
+		limiter:  hub.Guard.NewConnBucket(),
+		strikes:  hub.Guard.NewStrikes(),
+		oversize: hub.Guard.NewStrikes(),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	context.AfterFunc(c.ctx, c.Close)
+	return c
+}
+
+// RemoteIP returns the host part of addr.
+func RemoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
 	}
+	return host
 }
 
+// Close disconnects the client. It is safe to call more than once and from
+// any goroutine. The send queue is never closed, so rooms may keep calling
+// Send until they process the client's removal; it is a no-op by then.
 func (c *Client) Close() {
-	_ = c.conn.Close()
-	close(c.send)
+	c.closeOnce.Do(func() {
+		c.state.Store(int32(stateClosed))
+		c.cancel()
+		_ = c.conn.Close()
+	})
+}
+
+// State returns where the client is in its lifecycle.
+func (c *Client) State() clientState {
+	return clientState(c.state.Load())
+}
+
+// advance moves the client from one state to the next unless it was
+// closed in the meantime.
+func (c *Client) advance(from, to clientState) bool {
+	return c.state.CompareAndSwap(int32(from), int32(to))
 }
 
 func (c *Client) Send(msg protocol.WireMessage) {
+	c.enqueue(outbound{msg: msg})
+}
+
+// SendFrame queues a frame from encodeFrame. The frame may be shared with
+// other clients and must not be modified afterwards.
+func (c *Client) SendFrame(frame []byte) {
+	c.enqueue(outbound{frame: frame})
+}
+
+// SendFlushed queues frame like SendFrame and returns a channel that is
+// closed once it, and so everything queued before it, has been written.
+func (c *Client) SendFlushed(frame []byte) <-chan struct{} {
+	written := make(chan struct{})
+	c.enqueue(outbound{frame: frame, written: written})
+	return written
+}
+
+// enqueue queues out without blocking the caller. When the queue is full
+// the configured slow-consumer policy decides what gives.
+func (c *Client) enqueue(out outbound) {
 	select {
-	case c.send <- msg:
+	case <-c.ctx.Done():
+		return
 	default:
-		c.log.Warn("send buffer full, dropping message")
 	}
+	if c.policy == config.PolicySpill {
+		c.spill(out)
+		return
+	}
+	select {
+	case c.send <- out:
+		return
+	default:
+	}
+	if c.policy == config.PolicyDisconnect {
+		c.evict(protocol.ErrCodeSlowConsumer, fmt.Sprintf("too slow: more than %d messages waiting", cap(c.send)))
+		return
+	}
+	// drop oldest; other senders may refill the queue in between, so retry
+	for {
+		select {
+		case <-c.send:
+			c.dropTotal.Add(1)
+			if c.dropped.Add(1) == 1 {
+				c.log.Warn("send queue full, dropping oldest messages")
+			}
+		default:
+		}
+		select {
+		case c.send <- out:
+			return
+		default:
+		}
+	}
+}
+
+// spill queues out behind a full send queue, keeping order, and evicts
+// the client once the overflow buffer is full too.
+func (c *Client) spill(out outbound) {
+	c.overflowMu.Lock()
+	defer c.overflowMu.Unlock()
+	if len(c.overflow) == 0 {
+		select {
+		case c.send <- out:
+			return
+		default:
+			c.log.Warn("send queue full, spilling to overflow")
+		}
+	}
+	if len(c.overflow) >= config.Get().SendQueue.OverflowSize {
+		c.evict(protocol.ErrCodeSlowConsumer, fmt.Sprintf("too slow: more than %d messages waiting", cap(c.send)+len(c.overflow)))
+		return
+	}
+	c.overflow = append(c.overflow, out)
+	c.overflowLen.Store(int64(len(c.overflow)))
+}
+
+// refill moves spilled messages into the send queue as WriteLoop drains it.
+func (c *Client) refill() {
+	if c.overflowLen.Load() == 0 {
+		return
+	}
+	c.overflowMu.Lock()
+	defer c.overflowMu.Unlock()
+	n := 0
+fill:
+	for n < len(c.overflow) {
+		select {
+		case c.send <- c.overflow[n]:
+			n++
+		default:
+			break fill
+		}
+	}
+	c.overflow = append(c.overflow[:0], c.overflow[n:]...)
+	c.overflowLen.Store(int64(len(c.overflow)))
+}
+
+// evict has WriteLoop kick the client with an error, straight to the
+// connection since the send queue may be full. Unlike Kick it never blocks
+// the caller, so rooms and the hub can use it.
+func (c *Client) evict(code, reason string) {
+	c.evictOnce.Do(func() {
+		c.log.Warn("disconnecting client", "code", code, "reason", reason)
+		c.evictCode, c.evictReason = code, reason
+		close(c.evicted)
+	})
+}
+
+// QueueDepth reports how many messages wait to be written to the client,
+// including spilled ones, and how many were dropped so far.
+func (c *Client) QueueDepth() (queued, dropped int64) {
+	return int64(len(c.send)) + c.overflowLen.Load(), c.dropTotal.Load()
+}
+
+// Reply sends resp to the client correlated to req through reply_to.
+func (c *Client) Reply(req, resp protocol.WireMessage) {
+	resp.ReplyTo = req.ID
+	c.Send(resp)
+}
+
+// ReplyError sends an error response with a protocol.ErrCode* code.
+func (c *Client) ReplyError(req protocol.WireMessage, code, message string) {
+	c.Reply(req, *protocol.NewErrorMessage(code, message))
+}
+
+// Kick writes a final error straight to the connection and closes it.
+// ReadLoop then fails and unregisters the client as usual.
+func (c *Client) Kick(code, reason string) {
+	c.writeMu.Lock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(config.Get().WriteTimeout))
+	_ = json.NewEncoder(c.conn).Encode(protocol.NewErrorMessage(code, reason))
+	c.writeMu.Unlock()
+	c.Close()
 }
 
+// throttle handles a message over the per-connection limit and reports
+// whether the client was kicked for it.
+func (c *Client) throttle(msg protocol.WireMessage) bool {
+	if limit := c.hub.Guard.MaxStrikes(); limit > 0 && c.strikes.Add() >= limit {
+		c.log.Warn("rate limit: banning connection", "ip", c.ip)
+		c.hub.Guard.Ban(ratelimit.IPKey(c.ip))
+		c.Kick(protocol.ErrCodeBanned, "rate limit exceeded repeatedly; temporarily banned")
+		return true
+	}
+	c.ReplyError(msg, protocol.ErrCodeRateLimited, "rate limit exceeded: slow down; message dropped")
+	return false
+}
+
+// ReadLoop registers the client with the hub, then reads and routes its
+// messages until the connection fails or the client is closed.
 func (c *Client) ReadLoop() {
-	defer func() {
-		c.hub.Unregister <- c
-	}()
+	if !c.hub.register(c) {
+		c.Close()
+		return
+	}
+	defer c.hub.unregister(c)
 
-	reader := bufio.NewReaderSize(c.conn, config.Cfg.MaxMessageBytes)
+	var username string // c.Username belongs to the hub goroutine
+	reader := newFrameReader(c.conn, config.Get().MaxMessageBytes)
 	for {
-		line, err := reader.ReadBytes('\n')
+		// any frame, including a pong, proves the client is still there
+		_ = c.conn.SetReadDeadline(time.Now().Add(config.Get().ReadTimeout))
+		line, err := reader.ReadFrame()
+		if errors.Is(err, errFrameTooLarge) {
+			if c.rejectOversize(username, reader.limit) {
+				return
+			}
+			continue
+		}
 		if err != nil {
+			var nerr net.Error
+			switch {
+			case errors.As(err, &nerr) && nerr.Timeout():
+				c.log.Info("evicting unresponsive client", "username", username, "ip", c.ip)
+			case errors.Is(err, errFrameUnbounded):
+				c.log.Warn("disconnecting client: unterminated frame", "username", username, "ip", c.ip)
+				c.Kick(protocol.ErrCodeMessageTooLarge, fmt.Sprintf("frame exceeds %d bytes and never ends", reader.limit))
+			}
 			return
 		}
 		var msg protocol.WireMessage
 		if err := json.Unmarshal(line, &msg); err != nil {
 			c.log.Warn("bad json", "err", err)
+			c.ReplyError(msg, protocol.ErrCodeInvalidPayload, "invalid JSON: "+err.Error())
+			continue
+		}
+		if msg.Type == protocol.TypePong {
 			continue
 		}
-		if c.Username == "" && msg.Username != "" {
-			c.Username = msg.Username
+
+		if !c.limiter.Allow() {
+			if c.throttle(msg) {
+				return
+			}
+			continue
+		}
+
+		if msg.Type == protocol.TypePing {
+			c.Reply(msg, *protocol.NewPongMessage())
+			continue
+		}
+
+		if c.State() == stateConnected {
+			if msg.Type != protocol.TypeHello {
+				c.ReplyError(msg, protocol.ErrCodeInvalidState, "handshake required: send hello first")
+				continue
+			}
+			if err := msg.Validate(); err != nil {
+				c.ReplyError(msg, protocol.ErrCodeInvalidPayload, err.Error())
+				continue
+			}
+			welcome, err := c.handshake(msg)
+			if err != nil {
+				c.log.Info("handshake rejected", "agent", msg.Agent, "protocol_version", msg.ProtocolVersion, "err", err)
+				c.Kick(protocol.ErrCodeUnsupportedVersion, err.Error())
+				return
+			}
+			if !c.advance(stateConnected, stateGreeted) {
+				return
+			}
+			c.Reply(msg, *welcome)
+			continue
+		}
+
+		if msg.Type == protocol.TypeRegister || msg.Type == protocol.TypeLogin {
+			if c.State() != stateGreeted {
+				c.ReplyError(msg, protocol.ErrCodeInvalidState, "already authenticated")
+				continue
+			}
+			if err := msg.Validate(); err != nil {
+				c.ReplyError(msg, protocol.ErrCodeInvalidPayload, err.Error())
+				continue
+			}
+			env, err := c.authenticate(msg)
+			if err != nil {
+				c.log.Info("authentication failed", "username", msg.Username, "type", msg.Type, "err", err)
+				c.ReplyError(msg, authErrorCode(err), err.Error())
+				continue
+			}
+			if !c.advance(stateGreeted, stateAuthenticated) || !c.hub.submit(env) {
+				return
+			}
+			username = env.authUser
+			continue
+		}
+
+		if limit := config.Get().MaxBodyBytes; len(msg.Body) > limit {
+			c.ReplyError(msg, protocol.ErrCodeMessageTooLarge,
+				fmt.Sprintf("message body is %d bytes; the limit is %d", len(msg.Body), limit))
+			continue
+		}
+
+		if !c.hub.submit(envelope{sender: c, msg: msg}) {
+			return
 		}
-		c.hub.Inbound <- envelope{sender: c, msg: msg}
 	}
 }
 
+// maxOversizeFrames is how many oversized frames a connection may send
+// within the strike window before it is disconnected.
+const maxOversizeFrames = 3
+
+// rejectOversize answers a skipped oversized frame and reports whether the
+// client was kicked for sending too many.
+func (c *Client) rejectOversize(username string, limit int) bool {
+	if c.oversize.Add() >= maxOversizeFrames {
+		c.log.Warn("disconnecting client: repeated oversized frames", "username", username, "ip", c.ip)
+		c.Kick(protocol.ErrCodeMessageTooLarge, fmt.Sprintf("too many frames over %d bytes", limit))
+		return true
+	}
+	c.ReplyError(protocol.WireMessage{}, protocol.ErrCodeMessageTooLarge,
+		fmt.Sprintf("frame exceeds %d bytes; message dropped", limit))
+	return false
+}
+
+// handshake checks the client's protocol version and negotiates capabilities.
+func (c *Client) handshake(hello protocol.WireMessage) (*protocol.WireMessage, error) {
+	if hello.ProtocolVersion < protocol.MinProtocolVersion || hello.ProtocolVersion > protocol.ProtocolVersion {
+		return nil, fmt.Errorf("incompatible client: protocol version %d not supported (server supports %d-%d); please upgrade",
+			hello.ProtocolVersion, protocol.MinProtocolVersion, protocol.ProtocolVersion)
+	}
+
+	c.capabilities = c.capabilities[:0]
+	for _, name := range serverCapabilities {
+		if hello.HasCapability(name) {
+			c.capabilities = append(c.capabilities, name)
+		}
+	}
+	c.log.Debug("handshake complete", "agent", hello.Agent, "capabilities", c.capabilities)
+
+	return protocol.NewWelcomeMessage(serverAgent, c.capabilities, map[string]int{
+		protocol.LimitMaxMessageBytes: config.Get().MaxMessageBytes,
+		protocol.LimitMaxBodyBytes:    config.Get().MaxBodyBytes,
+		protocol.LimitRoomHistorySize: config.Get().RoomHistorySize,
+		protocol.LimitPingInterval:    int(config.Get().PingInterval.Seconds()),
+		protocol.LimitReadTimeout:     int(config.Get().ReadTimeout.Seconds()),
+	}), nil
+}
+
+// supports reports whether capability was negotiated in hello
+func (c *Client) supports(capability string) bool {
+	return slices.Contains(c.capabilities, capability)
+}
+
+// authenticate runs register/login on the read goroutine so password hashing
+// never blocks the hub. The returned envelope carries the verified username.
+func (c *Client) authenticate(msg protocol.WireMessage) (envelope, error) {
+	if c.hub.Guard.Banned(ratelimit.UserKey(msg.Username)) {
+		return envelope{}, fmt.Errorf("%s is %w", msg.Username, errBanned)
+	}
+	store := c.hub.store
+
+	var token string
+	var err error
+	switch {
+	case msg.Type == protocol.TypeRegister:
+		if err := security.ValidateCredentials(msg.Username, msg.Password); err != nil {
+			return envelope{}, fmt.Errorf("%w: %v", errMalformedCredentials, err)
+		}
+		token, err = chatstore.Register(store, msg.Username, msg.Password)
+	case msg.Password != "":
+		token, err = chatstore.LoginPassword(store, msg.Username, msg.Password)
+	case msg.Token != "":
+		token, err = msg.Token, chatstore.LoginToken(store, msg.Username, msg.Token)
+	default:
+		err = chatstore.ErrInvalidCredentials
+	}
+	if err != nil {
+		return envelope{}, err
+	}
+
+	resp := protocol.NewAuthOKMessage(msg.Username, token)
+	resp.ReplyTo = msg.ID
+	return envelope{
+		sender:   c,
+		msg:      *resp,
+		authUser: msg.Username,
+		resume:   msg.ResumeToken,
+	}, nil
+}
+
+// Errors from authenticate that aren't chatstore sentinels
+var (
+	errBanned               = errors.New("temporarily banned")
+	errMalformedCredentials = errors.New("invalid credentials")
+)
+
+// authErrorCode maps an authenticate error to its protocol error code.
+func authErrorCode(err error) string {
+	switch {
+	case errors.Is(err, errBanned):
+		return protocol.ErrCodeBanned
+	case errors.Is(err, errMalformedCredentials):
+		return protocol.ErrCodeInvalidPayload
+	case errors.Is(err, chatstore.ErrUserExists):
+		return protocol.ErrCodeUserExists
+	case errors.Is(err, chatstore.ErrInvalidCredentials), errors.Is(err, chatstore.ErrUserNotFound):
+		return protocol.ErrCodeAuthFailed
+	default:
+		return protocol.ErrCodeInternal
+	}
+}
+
+// WriteLoop drains the send queue and pings the client every PingInterval.
+// It warns the client after messages were dropped for it. A failed write
+// closes the client so ReadLoop unregisters it.
 func (c *Client) WriteLoop() {
-	enc := json.NewEncoder(c.conn)
-	for msg := range c.send {
-		_ = c.conn.SetWriteDeadline(time.Now().Add(config.Cfg.WriteTimeout))
-		if err := enc.Encode(msg); err != nil {
+	ticker := time.NewTicker(config.Get().PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var out outbound
+		select {
+		case out = <-c.send:
+		case <-c.ctx.Done():
+			return
+		case <-c.evicted:
+			c.Kick(c.evictCode, c.evictReason)
+			return
+		case <-ticker.C:
+			out.msg = *protocol.NewPingMessage()
+		}
+		c.writeMu.Lock()
+		_ = c.conn.SetWriteDeadline(time.Now().Add(config.Get().WriteTimeout))
+		err := writeOutbound(c.conn, out)
+		if n := c.dropped.Swap(0); n > 0 && err == nil {
+			warning := protocol.NewWarningMessage(fmt.Sprintf("%d message(s) were dropped because your connection fell behind", n))
+			err = writeOutbound(c.conn, outbound{msg: *warning})
+		}
+		c.writeMu.Unlock()
+		if err != nil {
 			c.log.Warn("write failed", "err", err)
+			c.Close()
 			return
 		}
+		if out.written != nil {
+			close(out.written)
+		}
+		c.refill()
 	}
 }
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+var (
+	// errFrameTooLarge means a frame was longer than the limit; it has been
+	// skipped and the next frame can be read.
+	errFrameTooLarge = errors.New("frame too large")
+	// errFrameUnbounded means a frame ran on for MaxSkipFactor times the
+	// limit without a newline; the stream is not worth reading further.
+	errFrameUnbounded = errors.New("frame has no end")
+)
+
+// MaxSkipFactor bounds how much of an oversized frame is read and thrown
+// away while looking for its end.
+const MaxSkipFactor = 64
+
+// frameReader reads newline-delimited frames of at most limit bytes,
+// never buffering more than that no matter what the peer sends.
+type frameReader struct {
+	r     *bufio.Reader
+	limit int
+}
+
+func newFrameReader(r io.Reader, limit int) *frameReader {
+	// room for the newline after a frame of exactly limit bytes
+	return &frameReader{r: bufio.NewReaderSize(r, limit+1), limit: limit}
+}
+
+// ReadFrame returns the next frame, newline included. The slice
+// is only valid until the next call. Oversized frames are skipped with
+// errFrameTooLarge; other errors come from the underlying reader.
+func (f *frameReader) ReadFrame() ([]byte, error) {
+	line, err := f.r.ReadSlice('\n')
+	switch {
+	case err == nil:
+		return line, nil
+	case errors.Is(err, bufio.ErrBufferFull):
+		return nil, f.skip(len(line))
+	default:
+		return nil, err
+	}
+}
+
+// skip discards the rest of an oversized frame, of which n bytes have
+// already been read.
+func (f *frameReader) skip(n int) error {
+	for n <= f.limit*MaxSkipFactor {
+		line, err := f.r.ReadSlice('\n')
+		n += len(line)
+		switch {
+		case err == nil:
+			return errFrameTooLarge
+		case !errors.Is(err, bufio.ErrBufferFull):
+			return err
+		}
+	}
+	return errFrameUnbounded
+}
@@ -4,63 +4,259 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/config"
 	"github.com/danieljhkim/chat-server/internal/protocol"
+	"github.com/danieljhkim/chat-server/internal/ratelimit"
 	"github.com/danieljhkim/chat-server/internal/security"
 )
 
 type envelope struct {
-	sender *Client
-	msg    protocol.WireMessage
+	sender   *Client
+	msg      protocol.WireMessage
+	authUser string // set only by Client.authenticate after credentials were verified
+	resume   string // resume token presented with the login, if any
 }
 
+// Hub owns connections, the room registry and who is in which room. Room
+// traffic itself runs on each Room's goroutine (see room.go).
 type Hub struct {
 	Rooms      map[string]*Room
 	Clients    map[*Client]struct{}
 	Register   chan *Client
 	Unregister chan *Client
 	Inbound    chan envelope
+	Guard      *ratelimit.Guard // rate limits and bans, shared with listeners
 
-	log *slog.Logger
+	store       chatstore.Store
+	memberships map[*Client]map[string]*Room    // rooms each client has joined
+	users       map[string]map[*Client]struct{} // username → its live connections
+	resumes     map[string]*resumeState         // by resume token, for dropped connections
+	queues      sync.Map                        // *Client → username, for QueueStats
+	reconfig    chan struct{}                   // ApplyConfig → Run: re-check connected clients
+	stopped     chan struct{}                   // closed when Run returns
+	log         *slog.Logger
 }
 
-func NewHub(log *slog.Logger) *Hub {
-	return &Hub{
-		Rooms:      make(map[string]*Room),
-		Clients:    make(map[*Client]struct{}),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
-		Inbound:    make(chan envelope, 1024),
-		log:        log.With("component", "hub"),
+// NewHub restores the rooms saved in store.
+func NewHub(log *slog.Logger, store chatstore.Store) (*Hub, error) {
+	cfg := config.Get()
+	h := &Hub{
+		Rooms:       make(map[string]*Room),
+		Clients:     make(map[*Client]struct{}),
+		Register:    make(chan *Client),
+		Unregister:  make(chan *Client),
+		Inbound:     make(chan envelope, 1024),
+		Guard:       ratelimit.NewGuard(guardLimits(cfg.RateLimit)),
+		store:       store,
+		memberships: make(map[*Client]map[string]*Room),
+		users:       make(map[string]map[*Client]struct{}),
+		resumes:     make(map[string]*resumeState),
+		reconfig:    make(chan struct{}, 1),
+		stopped:     make(chan struct{}),
+		log:         log.With("component", "hub"),
 	}
-}
+	h.Guard.SetBlocked(blockedKeys(cfg))
 
-func init() {
+	names, err := store.ListRooms()
+	if err != nil {
+		return nil, fmt.Errorf("load rooms: %w", err)
+	}
+	for _, name := range names {
+		room := NewRoom(name, cfg.RoomHistorySize, store, h.log)
+		recent, err := store.RoomHistory(name, cfg.RoomHistorySize)
+		if err != nil {
+			return nil, fmt.Errorf("load history for %s: %w", name, err)
+		}
+		for _, m := range recent {
+			room.Record(wireFromRoomMessage(m))
+		}
+		h.Rooms[name] = room
+	}
+	return h, nil
 }
 
+// Run is the hub's event loop. When ctx is cancelled it closes every
+// client and room it owns and returns.
 func (h *Hub) Run(ctx context.Context) {
+	sweep := time.NewTicker(resumeSweepInterval)
+	defer sweep.Stop()
+	defer close(h.stopped)
+
+	for _, r := range h.Rooms {
+		go r.Run()
+	}
+
 	for {
 		select {
 		case c := <-h.Register:
 			h.Clients[c] = struct{}{}
+			h.queues.Store(c, "")
 			h.log.Info("client connected", "addr", c.conn.RemoteAddr())
 		case c := <-h.Unregister:
 			h.removeClient(c)
 		case env := <-h.Inbound:
 			h.dispatch(env)
+		case now := <-sweep.C:
+			h.expireResumes(now)
+		case <-h.reconfig:
+			h.kickBlocked()
 		case <-ctx.Done():
-			h.log.Info("hub shutting down")
+			h.shutdown()
 			return
 		}
 	}
 }
 
-func (h *Hub) removeClient(c *Client) {
+// shutdown stops the hub in order: rooms finish their queued work, every
+// client is sent a server_shutdown notice and has until ShutdownTimeout to
+// receive it along with whatever was queued before it, then all
+// connections close. The caller persists the store once Run returns.
+func (h *Hub) shutdown() {
+	h.log.Info("hub shutting down", "clients", len(h.Clients), "rooms", len(h.Rooms))
 	for _, r := range h.Rooms {
-		r.Remove(c)
+		r.close()
+	}
+	for _, r := range h.Rooms {
+		<-r.done
+	}
+
+	notice := protocol.NewServerShutdownMessage("server is shutting down", int(config.Get().ShutdownRetry.Seconds()))
+	frame, err := encodeFrame(*notice)
+	if err != nil {
+		h.log.Error("encode shutdown notice failed", "err", err)
+	}
+	flushed := make(map[*Client]<-chan struct{}, len(h.Clients))
+	if frame != nil {
+		for c := range h.Clients {
+			flushed[c] = c.SendFlushed(frame)
+		}
+	}
+
+	deadline := time.NewTimer(config.Get().ShutdownTimeout)
+	defer deadline.Stop()
+	pending := len(flushed)
+drain:
+	for c, written := range flushed {
+		select {
+		case <-written:
+		case <-c.ctx.Done():
+		case <-deadline.C:
+			break drain
+		}
+		pending--
+	}
+	if pending > 0 {
+		h.log.Warn("shutdown timeout: closing clients with unsent messages", "clients", pending)
+	}
+
+	for c := range h.Clients {
+		c.Close()
+	}
+	h.log.Info("hub stopped")
+}
+
+// guardLimits converts the rate_limit config section.
+func guardLimits(rl config.RateLimit) ratelimit.Limits {
+	return ratelimit.Limits{
+		ConnRate:          rl.MessagesPerSecond,
+		ConnBurst:         rl.MessageBurst,
+		UserRate:          rl.UserPerSecond,
+		UserBurst:         rl.UserBurst,
+		RoomRate:          rl.RoomPerSecond,
+		RoomBurst:         rl.RoomBurst,
+		ConnectsPerMinute: rl.ConnectionsPerMinute,
+		MaxStrikes:        rl.MaxStrikes,
+		StrikeWindow:      rl.StrikeWindow,
+		BanDuration:       rl.BanDuration,
+	}
+}
+
+// blockedKeys lists the banned_users and banned_ips as Guard keys.
+func blockedKeys(cfg *config.Config) []string {
+	keys := make([]string, 0, len(cfg.BannedUsers)+len(cfg.BannedIPs))
+	for _, u := range cfg.BannedUsers {
+		keys = append(keys, ratelimit.UserKey(u))
+	}
+	for _, ip := range cfg.BannedIPs {
+		keys = append(keys, ratelimit.IPKey(ip))
+	}
+	return keys
+}
+
+// ApplyConfig puts a reloaded config's rate limits and ban lists into
+// effect and disconnects clients that are now banned. Other settings are
+// read from config.Get as they are used.
+func (h *Hub) ApplyConfig(cfg *config.Config) {
+	h.Guard.SetLimits(guardLimits(cfg.RateLimit))
+	h.Guard.SetBlocked(blockedKeys(cfg))
+	select {
+	case h.reconfig <- struct{}{}:
+	default: // a re-check is already pending
+	}
+}
+
+// kickBlocked disconnects clients whose IP or username is banned.
+func (h *Hub) kickBlocked() {
+	for c := range h.Clients {
+		if h.Guard.Banned(ratelimit.IPKey(c.ip)) || (c.Username != "" && h.Guard.Banned(ratelimit.UserKey(c.Username))) {
+			c.log.Info("disconnecting banned client", "username", c.Username, "ip", c.ip)
+			c.evict(protocol.ErrCodeBanned, "banned by the server operator")
+		}
+	}
+}
+
+// register, unregister and submit hand a client's events to the hub. They
+// never block once the hub has stopped, and register and submit give up
+// when the client is closed, reporting false.
+func (h *Hub) register(c *Client) bool {
+	select {
+	case h.Register <- c:
+		return true
+	case <-c.ctx.Done():
+	case <-h.stopped:
+	}
+	return false
+}
+
+func (h *Hub) unregister(c *Client) {
+	select {
+	case h.Unregister <- c:
+	case <-h.stopped:
+	}
+}
+
+func (h *Hub) submit(env envelope) bool {
+	select {
+	case h.Inbound <- env:
+		return true
+	case <-env.sender.ctx.Done():
+	case <-h.stopped:
+	}
+	return false
+}
+
+func (h *Hub) removeClient(c *Client) {
+	if _, ok := h.Clients[c]; !ok {
+		return // already removed, e.g. kicked before its ReadLoop unregistered
+	}
+	if !h.suspend(c) {
+		for _, r := range h.memberships[c] {
+			r.post(roomOp{kind: opDrop, client: c})
+		}
+	}
+	delete(h.memberships, c)
+	if conns := h.users[c.Username]; conns != nil {
+		delete(conns, c)
+		if len(conns) == 0 {
+			delete(h.users, c.Username)
+		}
 	}
 	delete(h.Clients, c)
+	h.queues.Delete(c)
 	c.Close()
 }
 
@@ -68,8 +264,12 @@ func (h *Hub) getOrCreateRoom(name string) *Room {
 	if r, ok := h.Rooms[name]; ok {
 		return r
 	}
-	r := NewRoom(name)
+	r := NewRoom(name, config.Get().RoomHistorySize, h.store, h.log)
 	h.Rooms[name] = r
+	go r.Run()
+	if err := h.store.SaveRoom(name); err != nil {
+		h.log.Error("save room failed", "room", name, "err", err)
+	}
 	return r
 }
 
@@ -80,23 +280,71 @@ func (h *Hub) getOrCreateRoom(name string) *Room {
 func (h *Hub) dispatch(env envelope) {
 	msg := env.msg
 	c := env.sender
+	if _, ok := h.Clients[c]; !ok {
+		// Inbound is buffered, so messages can still arrive after
+		// their sender's Unregister was handled
+		return
+	}
+
+	if env.authUser != "" {
+		h.handleAuthenticated(c, env)
+		return
+	}
+
+	if c.Username == "" {
+		switch msg.Type {
+		case protocol.TypeEcho:
+		default:
+			c.ReplyError(msg, protocol.ErrCodeNotAuthorized, "authentication required: send register or login first")
+			return
+		}
+	}
+	// identity comes from the connection, never from the payload
+	msg.Username = c.Username
+
+	if err := msg.Validate(); err != nil {
+		c.ReplyError(msg, protocol.ErrCodeInvalidPayload, err.Error())
+		return
+	}
+
+	if c.Username != "" && !h.allow(c, msg) {
+		return
+	}
 
 	switch msg.Type {
 
 	case protocol.TypeJoin:
 		h.handleJoin(c, msg)
 
-	case protocol.TypeRoomMsg:
+	case protocol.TypeLeave:
+		h.handleLeave(c, msg)
+
+	case protocol.TypeRoomMsg, protocol.TypeAction:
 		h.handleRoomMsg(c, msg)
 
+	case protocol.TypeGetHistory:
+		h.handleHistory(c, msg)
+
+	case protocol.TypeGetThread:
+		h.handleThread(c, msg)
+
+	case protocol.TypeEdit, protocol.TypeDelete:
+		h.handleAmend(c, msg)
+
+	case protocol.TypeReact, protocol.TypeUnreact:
+		h.handleReact(c, msg)
+
+	case protocol.TypeListMentions:
+		h.handleListMentions(c, msg)
+
 	case protocol.TypeSendDM:
 		h.handleDM(c, msg)
 
 	case protocol.TypeEcho:
-		c.Send(msg) // simple echo
+		c.Reply(msg, msg) // simple echo
 
 	case protocol.TypeListRooms:
-		h.handleListRooms(c)
+		h.handleListRooms(c, msg)
 
 	case protocol.TypeListUsers:
 		h.handleListUsers(c, msg)
@@ -105,56 +353,263 @@ func (h *Hub) dispatch(env envelope) {
 		h.handleListDM(c, msg)
 	default:
 		h.log.Warn("unknown msg type", "type", msg.Type)
+		c.ReplyError(msg, protocol.ErrCodeUnknownType, fmt.Sprintf("unknown message type %q", msg.Type))
+	}
+}
+
+// allow applies the per-username rate limit. Repeat offenders are banned
+// and disconnected.
+func (h *Hub) allow(c *Client, msg protocol.WireMessage) bool {
+	if !h.Guard.AllowUser(c.Username) {
+		if h.Guard.StrikeUser(c.Username) {
+			h.log.Warn("rate limit: banning user", "username", c.Username)
+			h.Guard.Ban(ratelimit.UserKey(c.Username))
+			c.evict(protocol.ErrCodeBanned, "rate limit exceeded repeatedly; temporarily banned")
+			return false
+		}
+		c.ReplyError(msg, protocol.ErrCodeRateLimited, "rate limit exceeded: too many messages from "+c.Username+"; message dropped")
+		return false
+	}
+	return true
+}
+
+// allowRoom applies the per-room rate limit. It is only charged for
+// members, so outsiders can't use up a room's budget.
+func (h *Hub) allowRoom(c *Client, msg protocol.WireMessage) bool {
+	if !h.Guard.AllowRoom(msg.Room) {
+		c.ReplyError(msg, protocol.ErrCodeRateLimited, "rate limit exceeded: room "+msg.Room+" is busy; message dropped")
+		return false
+	}
+	return true
+}
+
+func (h *Hub) handleAuthenticated(c *Client, env envelope) {
+	c.Username = env.authUser
+	h.log.Info("client authenticated", "username", c.Username, "addr", c.conn.RemoteAddr())
+	if h.users[c.Username] == nil {
+		h.users[c.Username] = make(map[*Client]struct{})
+	}
+	h.users[c.Username][c] = struct{}{}
+	h.queues.Store(c, c.Username)
+	if env.resume != "" {
+		h.resume(c, env.resume)
+	}
+	c.resumeToken = newResumeToken()
+
+	resp := env.msg // reply_to already set by Client.authenticate
+	resp.ResumeToken = c.resumeToken
+	c.Send(resp)
+	if motd := config.Get().MOTD; motd != "" {
+		c.Send(*protocol.NewInfoMessage(motd))
 	}
+	h.deliverPending(c)
 }
 
 func (h *Hub) handleJoin(c *Client, msg protocol.WireMessage) {
-	if msg.Room == "" {
-		return
+	if _, ok := h.Rooms[msg.Room]; !ok {
+		if limit := config.Get().MaxRooms; limit > 0 && len(h.Rooms) >= limit {
+			h.log.Warn("room limit reached", "room", msg.Room, "limit", limit)
+			c.ReplyError(msg, protocol.ErrCodeRoomLimit, fmt.Sprintf("the server already has its limit of %d rooms; join an existing one", limit))
+			return
+		}
 	}
 	room := h.getOrCreateRoom(msg.Room)
-	room.Add(c)
-	notice := protocol.WireMessage{
-		Type:     protocol.TypeUserJoined,
-		Room:     room.Name,
-		Body:     fmt.Sprintf("%s joined the room.", msg.Username),
-		Username: msg.Username,
+	if !room.tryPost(roomOp{kind: opJoin, client: c, msg: msg}) {
+		h.roomBusy(c, msg)
+		return
 	}
-	room.Broadcast(notice, nil)
+	h.addMembership(c, room)
 }
 
-func (h *Hub) handleRoomMsg(_ *Client, msg protocol.WireMessage) {
-	if msg.Room == "" {
-		msg.Room = "general" // default room if not specified
+func (h *Hub) handleLeave(c *Client, msg protocol.WireMessage) {
+	room, ok := h.memberRoom(c, msg)
+	if !ok {
+		return
 	}
-	if room, ok := h.Rooms[msg.Room]; ok {
-		// let sender’s Username go through unchanged
-		msg.Body = security.SanitizeInput(msg.Body)
-		room.Broadcast(msg, nil)
+	// leaving must not be lost to a full inbox
+	delete(h.memberships[c], room.Name)
+	room.post(roomOp{kind: opLeave, client: c, msg: msg})
+}
+
+func (h *Hub) handleRoomMsg(c *Client, msg protocol.WireMessage) {
+	if room, ok := h.memberRoom(c, msg); ok && h.allowRoom(c, msg) {
+		h.forward(room, opPost, c, msg)
 	}
 }
 
-func (h *Hub) handleDM(_ *Client, msg protocol.WireMessage) {
-	if msg.Target == "" {
+func (h *Hub) handleHistory(c *Client, msg protocol.WireMessage) {
+	if !c.supports(protocol.CapHistory) {
+		c.ReplyError(msg, protocol.ErrCodeInvalidState, "history was not negotiated in hello")
 		return
 	}
-	for cl := range h.Clients {
-		if cl.Username == msg.Target {
-			msg.Body = security.SanitizeInput(msg.Body)
-			store := chatstore.GetDMStore()
-			store.AddMessage(chatstore.DM{
-				Sender:    msg.Username,
-				Recipient: msg.Target,
-				Body:      msg.Body,
-				Timestamp: msg.Timestamp,
-				Read:      false,
-			})
+	if room, ok := h.lookupRoom(c, msg); ok {
+		h.forward(room, opHistory, c, msg)
+	}
+}
+
+func (h *Hub) handleListUsers(c *Client, msg protocol.WireMessage) {
+	if room, ok := h.lookupRoom(c, msg); ok {
+		h.forward(room, opUsers, c, msg)
+	}
+}
+
+// forward hands a request to the room's goroutine.
+func (h *Hub) forward(room *Room, kind roomOpKind, c *Client, msg protocol.WireMessage) {
+	if !room.tryPost(roomOp{kind: kind, client: c, msg: msg}) {
+		h.roomBusy(c, msg)
+	}
+}
+
+func (h *Hub) roomBusy(c *Client, msg protocol.WireMessage) {
+	h.log.Warn("room inbox full", "room", msg.Room)
+	c.ReplyError(msg, protocol.ErrCodeRateLimited, fmt.Sprintf("room %q is busy; try again", msg.Room))
+}
+
+func (h *Hub) addMembership(c *Client, room *Room) {
+	if h.memberships[c] == nil {
+		h.memberships[c] = make(map[string]*Room)
+	}
+	h.memberships[c][room.Name] = room
+}
+
+// lookupRoom returns the room msg targets, replying room_not_found if it
+// does not exist.
+func (h *Hub) lookupRoom(c *Client, msg protocol.WireMessage) (*Room, bool) {
+	room, ok := h.Rooms[msg.Room]
+	if !ok {
+		c.ReplyError(msg, protocol.ErrCodeRoomNotFound, fmt.Sprintf("room %q does not exist", msg.Room))
+	}
+	return room, ok
+}
+
+// memberRoom is lookupRoom for requests that need c to have joined the room.
+func (h *Hub) memberRoom(c *Client, msg protocol.WireMessage) (*Room, bool) {
+	room, ok := h.lookupRoom(c, msg)
+	if ok && h.memberships[c][room.Name] == nil {
+		c.ReplyError(msg, protocol.ErrCodeNotAuthorized, fmt.Sprintf("you are not in room %q; join it first", msg.Room))
+		return nil, false
+	}
+	return room, ok
+}
+
+func roomMessageFromWire(msg protocol.WireMessage) chatstore.RoomMessage {
+	return chatstore.RoomMessage{
+		Type:      msg.Type,
+		Room:      msg.Room,
+		Username:  msg.Username,
+		Body:      msg.Body,
+		Timestamp: msg.Timestamp,
+		Seq:       msg.Seq,
+		ID:        msg.MsgID,
+		Edited:    msg.Edited,
+		Deleted:   msg.Deleted,
+		ParentID:  msg.ParentID,
+		ThreadID:  msg.ThreadID,
+		Reactions: reactionsToStore(msg.Reactions),
+		Mentions:  msg.Mentions,
+	}
+}
+
+func wireFromRoomMessage(m chatstore.RoomMessage) protocol.WireMessage {
+	return protocol.WireMessage{
+		Type:      m.Type,
+		Room:      m.Room,
+		Username:  m.Username,
+		Body:      m.Body,
+		Timestamp: m.Timestamp,
+		Seq:       m.Seq,
+		MsgID:     m.ID,
+		Edited:    m.Edited,
+		Deleted:   m.Deleted,
+		ParentID:  m.ParentID,
+		ThreadID:  m.ThreadID,
+		Reactions: reactionsFromStore(m.Reactions),
+		Mentions:  m.Mentions,
+	}
+}
+
+// DM delivery status reported to the sender in dm_ack
+const (
+	dmDelivered = "delivered"
+	dmQueued    = "queued"
+)
+
+func (h *Hub) handleDM(c *Client, msg protocol.WireMessage) {
+	if !chatstore.UserExists(h.store, msg.Target) {
+		c.ReplyError(msg, protocol.ErrCodeUserNotFound, fmt.Sprintf("unknown user %q", msg.Target))
+		return
+	}
+
+	dm := chatstore.DM{
+		ID:        newMessageID(),
+		Sender:    msg.Username,
+		Recipient: msg.Target,
+		Body:      security.SanitizeInput(msg.Body),
+		Timestamp: time.Now(),
+		Read:      false,
+	}
+	if err := h.store.AddDM(dm); err != nil {
+		h.log.Error("store dm failed", "err", err)
+		c.ReplyError(msg, protocol.ErrCodeInternal, "failed to store direct message")
+		return
+	}
+
+	delivered := false
+	if sessions := h.users[dm.Recipient]; len(sessions) > 0 {
+		frame, err := encodeFrame(dmMessage(dm))
+		if err != nil {
+			h.log.Error("encode dm failed", "err", err)
+			c.ReplyError(msg, protocol.ErrCodeInternal, "failed to deliver direct message")
 			return
 		}
+		for cl := range sessions {
+			cl.SendFrame(frame)
+		}
+		delivered = true
+	}
+
+	status := dmDelivered
+	if !delivered {
+		if err := h.store.EnqueueDM(dm); err != nil {
+			h.log.Error("queue dm failed", "err", err)
+		}
+		status = dmQueued
+	}
+	ack := protocol.NewDMAckMessage(dm.Recipient, status)
+	ack.MsgID = dm.ID
+	ack.Timestamp = dm.Timestamp
+	if c.supports(protocol.CapAcks) {
+		c.Reply(msg, *ack)
 	}
 }
 
-func (h *Hub) handleListRooms(c *Client) {
+// deliverPending flushes DMs queued while the user was offline and
+// announces their unread count and any mentions waiting.
+func (h *Hub) deliverPending(c *Client) {
+	pending, err := h.store.TakePendingDMs(c.Username)
+	if err != nil {
+		h.log.Error("load pending dms failed", "username", c.Username, "err", err)
+	}
+	for _, dm := range pending {
+		c.Send(dmMessage(dm))
+	}
+	if n, _ := h.store.UnreadDMCount(c.Username); n > 0 {
+		c.Send(*protocol.NewInfoMessage(fmt.Sprintf("You have %d unread direct message(s).", n)))
+	}
+	if n, _ := h.store.MentionCount(c.Username); n > 0 {
+		c.Send(*protocol.NewInfoMessage(fmt.Sprintf("You were mentioned %d time(s) while away.", n)))
+	}
+}
+
+func dmMessage(dm chatstore.DM) protocol.WireMessage {
+	msg := protocol.NewDirectMessage(dm.Sender, dm.Recipient, dm.Body)
+	msg.Timestamp = dm.Timestamp
+	msg.MsgID = dm.ID
+	msg.Edited = dm.Edited
+	return *msg
+}
+
+func (h *Hub) handleListRooms(c *Client, msg protocol.WireMessage) {
 	names := make([]string, 0, len(h.Rooms))
 	for name := range h.Rooms {
 		names = append(names, name)
@@ -164,29 +619,23 @@ func (h *Hub) handleListRooms(c *Client) {
 		Type:  protocol.TypeRoomsList,
 		Rooms: names,
 	}
-	c.Send(resp)
+	c.Reply(msg, resp)
 }
 
 func (h *Hub) handleListDM(c *Client, msg protocol.WireMessage) {
-	store := chatstore.GetDMStore()
-	dms := store.GetMessages(msg.Username)
+	dms, err := h.store.GetDMs(msg.Username)
+	if err != nil {
+		h.log.Error("load dms failed", "username", msg.Username, "err", err)
+		c.ReplyError(msg, protocol.ErrCodeInternal, "failed to load direct messages")
+		return
+	}
+	if err := h.store.MarkDMsRead(msg.Username); err != nil {
+		h.log.Error("mark dms read failed", "username", msg.Username, "err", err)
+	}
 
 	resp := protocol.WireMessage{
 		Type: protocol.TypeDMList,
 		DMs:  dms,
 	}
-	c.Send(resp)
-}
-
-func (h *Hub) handleListUsers(c *Client, msg protocol.WireMessage) {
-	room := msg.Room
-	names := make([]string, 0, len(h.Rooms[room].Members))
-	for cl := range h.Rooms[room].Members {
-		names = append(names, cl.Username)
-	}
-	resp := protocol.WireMessage{
-		Type:  protocol.TypeUserList,
-		Users: names,
-	}
-	c.Send(resp)
+	c.Reply(msg, resp)
 }
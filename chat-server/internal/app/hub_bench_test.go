@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/config"
+	"github.com/danieljhkim/chat-server/internal/protocol"
+)
+
+// benchHub runs a hub over an in-memory store with logging discarded.
+func benchHub(b *testing.B) *Hub {
+	b.Helper()
+	saved := config.Get()
+	b.Cleanup(func() { config.Set(saved) })
+	cfg := *saved
+	cfg.SendQueue = config.SendQueue{Size: 256, Policy: config.PolicyDropOldest}
+	config.Set(&cfg)
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h, err := NewHub(log, chatstore.NewMemoryStore(1000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.Cleanup(cancel)
+	go h.Run(ctx)
+	return h
+}
+
+// benchClient simulates a logged-in connection. Instead of a socket, a
+// goroutine drains its send queue and counts what was delivered. It stands
+// in for WriteLoop, so it also closes SendFlushed's channels.
+func benchClient(b *testing.B, h *Hub, name string, delivered *atomic.Int64) *Client {
+	b.Helper()
+	conn, peer := net.Pipe()
+	c := NewClient(context.Background(), conn, h, h.log)
+	c.capabilities = serverCapabilities // as if negotiated in hello
+	b.Cleanup(func() {
+		c.Close()
+		peer.Close()
+	})
+	go func() {
+		for {
+			select {
+			case out := <-c.send:
+				if out.written != nil {
+					close(out.written) // a settle marker, not a delivery
+					continue
+				}
+				delivered.Add(1)
+			case <-c.ctx.Done():
+				return
+			}
+		}
+	}()
+	h.Register <- c
+	h.Inbound <- envelope{sender: c, msg: *protocol.NewAuthOKMessage(name, ""), authUser: name}
+	return c
+}
+
+// benchRoom fills a room with members simulated clients and returns it
+// with the clients, once every join has been processed. Joins are sent in
+// batches the room's inbox can hold.
+func benchRoom(b *testing.B, h *Hub, name string, members int, delivered *atomic.Int64) (*Room, []*Client) {
+	b.Helper()
+	clients := make([]*Client, members)
+	for i := range clients {
+		user := fmt.Sprintf("%s-user%d", name, i)
+		clients[i] = benchClient(b, h, user, delivered)
+		h.Inbound <- envelope{sender: clients[i], msg: *protocol.NewJoinMessage(name, user)}
+		// joins beyond the inbox would be refused as busy
+		if (i+1)%(roomQueueSize/2) == 0 {
+			barrier(b, h, name)
+		}
+	}
+	barrier(b, h, name)
+	settle(b, clients)
+	room, ok := h.Rooms[name]
+	if !ok {
+		b.Fatalf("room %s was not created", name)
+	}
+	// a join that found the inbox full was refused, not queued
+	if n := room.Count(); n != members {
+		b.Fatalf("room %s has %d members, want %d", name, n, members)
+	}
+	return room, clients
+}
+
+// settle waits for the clients' drain goroutines to count everything
+// queued so far, so join notices don't count towards a benchmark's
+// deliveries. Each client is sent a marker, which its drain goroutine
+// only reaches once it has counted what came before.
+func settle(b *testing.B, clients []*Client) {
+	b.Helper()
+	marker, err := encodeFrame(*protocol.NewInfoMessage("settle"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, c := range clients {
+		select {
+		case <-c.SendFlushed(marker):
+		case <-time.After(time.Minute):
+			b.Fatal("drain goroutine never reached the settle marker")
+		}
+	}
+}
+
+// barrier waits until the hub and room have handled everything queued
+// before it, by sending a request and waiting for its reply.
+func barrier(b *testing.B, h *Hub, room string) {
+	b.Helper()
+	req := *protocol.NewHistoryRequest(room, 1)
+	req.ID = "barrier"
+	probe(b, h, req)
+}
+
+// hubBarrier is barrier for the hub alone.
+func hubBarrier(b *testing.B, h *Hub) {
+	b.Helper()
+	probe(b, h, protocol.WireMessage{Type: protocol.TypeListRooms, ID: "hub-barrier"})
+}
+
+// probe sends req through the hub from a fresh client and waits for the
+// reply.
+func probe(b *testing.B, h *Hub, req protocol.WireMessage) {
+	b.Helper()
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	c := NewClient(context.Background(), conn, h, h.log)
+	c.capabilities = serverCapabilities
+	defer c.Close()
+	h.Register <- c
+	h.Inbound <- envelope{sender: c, msg: *protocol.NewAuthOKMessage("probe", ""), authUser: "probe"}
+	h.Inbound <- envelope{sender: c, msg: req}
+	awaitReply(b, c, req.ID)
+}
+
+// awaitReply waits for the reply to id. An error reply, such as a busy
+// room refusing the request, means nothing was waited for and fails b.
+func awaitReply(b *testing.B, c *Client, id string) {
+	b.Helper()
+	timeout := time.After(time.Minute)
+	for {
+		select {
+		case out := <-c.send:
+			if out.msg.ReplyTo != id {
+				continue
+			}
+			if out.msg.Type == protocol.TypeError {
+				b.Fatalf("%s: %s: %s", id, out.msg.Code, out.msg.Message)
+			}
+			return
+		case <-timeout:
+			b.Fatal("no reply to " + id)
+		}
+	}
+}
+
+// roomBarrier waits until room has processed everything queued before it.
+func roomBarrier(b *testing.B, h *Hub, room *Room) {
+	b.Helper()
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	probe := NewClient(context.Background(), conn, h, h.log)
+	defer probe.Close()
+
+	req := *protocol.NewHistoryRequest(room.Name, 1)
+	req.ID = "room-barrier"
+	room.post(roomOp{kind: opHistory, client: probe, msg: req})
+	awaitReply(b, probe, req.ID)
+}
+
+// BenchmarkRoomFanout measures one room actor delivering to thousands of
+// members. Each op is one room_msg broadcast to every member; %delivered
+// falls below 100 when the drain goroutines fall behind and the
+// slow-consumer policy drops messages.
+func BenchmarkRoomFanout(b *testing.B) {
+	for _, members := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("members=%d", members), func(b *testing.B) {
+			h := benchHub(b)
+			var delivered atomic.Int64
+			room, clients := benchRoom(b, h, "bench", members, &delivered)
+			delivered.Store(0)
+
+			sender := clients[0]
+			msg := *protocol.NewRoomMessage("bench", "bench-user0", "hello")
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				room.post(roomOp{kind: opPost, client: sender, msg: msg})
+			}
+			roomBarrier(b, h, room)
+			elapsed := time.Since(start)
+			b.StopTimer()
+			settle(b, clients)
+
+			b.ReportMetric(float64(b.N*members)/elapsed.Seconds(), "fanout/s")
+			b.ReportMetric(float64(delivered.Load())/float64(b.N*members)*100, "%delivered")
+		})
+	}
+}
+
+// BenchmarkRoomsParallel posts to many rooms at once; each room's actor
+// works independently, so throughput scales with cores instead of being
+// bound to the hub goroutine.
+func BenchmarkRoomsParallel(b *testing.B) {
+	const rooms, perRoom = 64, 50
+	h := benchHub(b)
+	var delivered atomic.Int64
+	rs := make([]*Room, rooms)
+	senders := make([]*Client, rooms)
+	msgs := make([]protocol.WireMessage, rooms)
+	for r := range rs {
+		name := fmt.Sprintf("room%d", r)
+		var clients []*Client
+		rs[r], clients = benchRoom(b, h, name, perRoom, &delivered)
+		senders[r] = clients[0]
+		msgs[r] = *protocol.NewRoomMessage(name, name+"-user0", "hello")
+	}
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		r := i % rooms
+		rs[r].post(roomOp{kind: opPost, client: senders[r], msg: msgs[r]})
+	}
+	for _, room := range rs {
+		roomBarrier(b, h, room)
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+	b.ReportMetric(float64(b.N*perRoom)/elapsed.Seconds(), "fanout/s")
+}
+
+// BenchmarkDMRouting sends DMs between thousands of online users through
+// the hub; the username index keeps each lookup O(1).
+func BenchmarkDMRouting(b *testing.B) {
+	const users = 5000
+	h := benchHub(b)
+	var delivered atomic.Int64
+	clients := make([]*Client, users)
+	names := make([]string, users)
+	for i := range clients {
+		names[i] = fmt.Sprintf("user%d", i)
+		if err := h.store.CreateUser(chatstore.User{Username: names[i]}); err != nil {
+			b.Fatal(err)
+		}
+		clients[i] = benchClient(b, h, names[i], &delivered)
+	}
+	hubBarrier(b, h)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		from, to := i%users, (i*7+1)%users
+		dm := protocol.NewDirectMessage(names[from], names[to], "hi")
+		dm.Type = protocol.TypeSendDM
+		h.Inbound <- envelope{sender: clients[from], msg: *dm}
+	}
+	hubBarrier(b, h)
+}
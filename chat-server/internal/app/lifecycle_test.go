@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/config"
+	"github.com/danieljhkim/chat-server/internal/protocol"
+)
+
+// lifecycleHub runs a hub with the config the client loops need. The
+// returned cancel starts its shutdown; cleanup waits for it to finish.
+func lifecycleHub(t *testing.T) (*Hub, context.CancelFunc) {
+	t.Helper()
+	saved := config.Get()
+	t.Cleanup(func() { config.Set(saved) })
+	cfg := *saved
+	cfg.MaxMessageBytes = 4096
+	cfg.MaxBodyBytes = 2000
+	cfg.ReadTimeout = 5 * time.Second
+	cfg.WriteTimeout = time.Second
+	cfg.PingInterval = 50 * time.Millisecond
+	cfg.ResumeWindow = time.Second
+	cfg.RoomHistorySize = 10
+	cfg.SendQueue = config.SendQueue{Size: 16, Policy: config.PolicyDropOldest}
+	cfg.ShutdownTimeout = 2 * time.Second
+	config.Set(&cfg)
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h, err := NewHub(log, chatstore.NewMemoryStore(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		h.Run(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	return h, cancel
+}
+
+// lifecycleUsers registers n accounts and returns their session tokens.
+func lifecycleUsers(t *testing.T, h *Hub, n int) []string {
+	t.Helper()
+	tokens := make([]string, n)
+	for i := range tokens {
+		token, err := chatstore.Register(h.store, fmt.Sprintf("user%d", i), "secret123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[i] = token
+	}
+	return tokens
+}
+
+// testConn is a client running its real loops over an in-memory pipe.
+type testConn struct {
+	client *Client
+	peer   net.Conn
+	cancel context.CancelFunc
+	loops  sync.WaitGroup
+}
+
+func dialTest(h *Hub) *testConn {
+	conn, peer := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	tc := &testConn{client: NewClient(ctx, conn, h, h.log), peer: peer, cancel: cancel}
+	tc.loops.Add(2)
+	go func() {
+		defer tc.loops.Done()
+		tc.client.ReadLoop()
+	}()
+	go func() {
+		defer tc.loops.Done()
+		tc.client.WriteLoop()
+	}()
+	go func() { _, _ = io.Copy(io.Discard, peer) }()
+	return tc
+}
+
+// write sends frames as the remote end would, stopping at the first
+// error since the server may close the connection at any point.
+func (tc *testConn) write(frames ...string) {
+	for _, f := range frames {
+		if _, err := io.WriteString(tc.peer, f+"\n"); err != nil {
+			return
+		}
+	}
+}
+
+// waitClosed fails t unless both loops exit within timeout.
+func (tc *testConn) waitClosed(t *testing.T, name string, timeout time.Duration) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		tc.loops.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Errorf("%s: read/write loops still running after %s", name, timeout)
+	}
+	_ = tc.peer.Close()
+	tc.cancel()
+}
+
+// waitNoClients waits for the hub to forget every client.
+func waitNoClients(t *testing.T, h *Hub) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for len(h.QueueStats()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("hub still tracks %d clients", len(h.QueueStats()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestClientLifecycleStress connects and disconnects thousands of clients
+// concurrently, ending each one a different way while rooms and DMs are
+// still sending to it. Run it with -race.
+func TestClientLifecycleStress(t *testing.T) {
+	cycles := 2000
+	if testing.Short() {
+		cycles = 200
+	}
+	h, _ := lifecycleHub(t)
+	tokens := lifecycleUsers(t, h, 4)
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 64)
+	for i := 0; i < cycles; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			user := i % len(tokens)
+			tc := dialTest(h)
+			tc.write(`{"type":"hello","protocol_version":1}`)
+			if i%5 == 0 {
+				// hang up mid-handshake
+				_ = tc.peer.Close()
+				tc.waitClosed(t, fmt.Sprintf("cycle %d", i), 10*time.Second)
+				return
+			}
+			tc.write(
+				fmt.Sprintf(`{"type":"login","username":"user%d","token":"%s"}`, user, tokens[user]),
+				fmt.Sprintf(`{"type":"join","room":"stress%d"}`, i%8),
+				fmt.Sprintf(`{"type":"room_msg","room":"stress%d","body":"hello %d"}`, i%8, i),
+				fmt.Sprintf(`{"type":"send_dm","target":"user%d","body":"hi"}`, (user+1)%len(tokens)),
+			)
+			switch i % 5 {
+			case 1: // remote hangs up
+				_ = tc.peer.Close()
+			case 2: // server closes while others keep sending to it
+				info := *protocol.NewInfoMessage(fmt.Sprintf("notice %d", i))
+				go tc.client.Send(info)
+				tc.client.Close()
+				tc.client.Send(info)
+			case 3: // the connection's context is cancelled
+				tc.cancel()
+			case 4: // leave first, then hang up
+				tc.write(fmt.Sprintf(`{"type":"leave","room":"stress%d"}`, i%8))
+				_ = tc.peer.Close()
+			}
+			tc.waitClosed(t, fmt.Sprintf("cycle %d", i), 10*time.Second)
+		}(i)
+	}
+	wg.Wait()
+	waitNoClients(t, h)
+}
+
+// TestHubShutdownStopsClients stops the hub under live clients: every
+// loop must exit, and clients arriving afterwards must not block.
+func TestHubShutdownStopsClients(t *testing.T) {
+	h, stop := lifecycleHub(t)
+	tokens := lifecycleUsers(t, h, 2)
+
+	conns := make([]*testConn, 200)
+	for i := range conns {
+		conns[i] = dialTest(h)
+		go conns[i].write(
+			`{"type":"hello","protocol_version":1}`,
+			fmt.Sprintf(`{"type":"login","username":"user%d","token":"%s"}`, i%2, tokens[i%2]),
+			`{"type":"join","room":"lobby"}`,
+			`{"type":"room_msg","room":"lobby","body":"hi"}`,
+		)
+	}
+	time.Sleep(100 * time.Millisecond)
+	stop()
+
+	for i, tc := range conns {
+		tc.waitClosed(t, fmt.Sprintf("client %d", i), 10*time.Second)
+	}
+	late := dialTest(h)
+	late.write(`{"type":"hello","protocol_version":1}`)
+	late.waitClosed(t, "client after shutdown", 10*time.Second)
+	if late.client.State() != stateClosed {
+		t.Errorf("client after shutdown is in state %d, want closed", late.client.State())
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/protocol"
+)
+
+// maxMentions caps how many users one message can mention
+const maxMentions = 20
+
+// mentionPattern finds @username. The @ must not follow a username
+// character, so addresses like bob@example.com don't count.
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.-])@([A-Za-z0-9_.-]{1,32})`)
+
+// parseMentions returns the registered users body mentions, in order of
+// first appearance.
+func (r *Room) parseMentions(body string) []string {
+	var users []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := m[1]
+		if !chatstore.UserExists(r.store, name) {
+			// trailing punctuation, as in "thanks @bob."
+			name = strings.TrimRight(name, ".-")
+			if name == "" || !chatstore.UserExists(r.store, name) {
+				continue
+			}
+		}
+		if !slices.Contains(users, name) {
+			users = append(users, name)
+		}
+		if len(users) == maxMentions {
+			break
+		}
+	}
+	return users
+}
+
+// recordMentions stores a mention for each of users who has no session in
+// the room to see msg arrive. Authors are never notified of themselves.
+func (r *Room) recordMentions(msg protocol.WireMessage, users []string) {
+	present := make(map[string]bool, len(r.Members))
+	for c := range r.Members {
+		present[c.Username] = true
+	}
+	for _, u := range users {
+		if u == msg.Username || present[u] {
+			continue
+		}
+		err := r.store.AddMention(chatstore.Mention{
+			Username:  u,
+			Room:      r.Name,
+			MsgID:     msg.MsgID,
+			Seq:       msg.Seq,
+			From:      msg.Username,
+			Timestamp: msg.Timestamp,
+		})
+		if err != nil {
+			r.log.Error("store mention failed", "username", u, "err", err)
+		}
+	}
+}
+
+// handleListMentions replies with the messages c was mentioned in while
+// away and clears them. Messages since deleted, or edited to drop the
+// mention, are left out.
+func (h *Hub) handleListMentions(c *Client, msg protocol.WireMessage) {
+	mentions, err := h.store.TakeMentions(c.Username)
+	if err != nil {
+		h.log.Error("load mentions failed", "username", c.Username, "err", err)
+		c.ReplyError(msg, protocol.ErrCodeInternal, "failed to load mentions")
+		return
+	}
+	msgs := make([]protocol.WireMessage, 0, len(mentions))
+	for _, m := range mentions {
+		stored, err := h.store.GetRoomMessage(m.Room, m.MsgID)
+		if err != nil {
+			// trimmed from history: all that is left is who and where
+			msgs = append(msgs, protocol.WireMessage{
+				Type:      protocol.TypeRoomMsg,
+				Room:      m.Room,
+				MsgID:     m.MsgID,
+				Seq:       m.Seq,
+				Username:  m.From,
+				Timestamp: m.Timestamp,
+			})
+			continue
+		}
+		if stored.Deleted || !slices.Contains(stored.Mentions, c.Username) {
+			continue
+		}
+		msgs = append(msgs, wireFromRoomMessage(stored))
+	}
+	c.Reply(msg, *protocol.NewMentionListResponse(msgs))
+}
@@ -0,0 +1,30 @@
+package app
+
+import "sort"
+
+// QueueStat is one client's send queue, as reported by QueueStats.
+type QueueStat struct {
+	Addr     string `json:"addr"`
+	Username string `json:"username,omitempty"`
+	Queued   int64  `json:"queued"`  // waiting to be written, including spilled
+	Dropped  int64  `json:"dropped"` // dropped by the drop_oldest policy
+}
+
+// QueueStats reports the send queue of every connected client, deepest
+// first. It is safe to call from any goroutine, e.g. an expvar.Func.
+func (h *Hub) QueueStats() []QueueStat {
+	stats := []QueueStat{}
+	h.queues.Range(func(key, value any) bool {
+		c := key.(*Client)
+		queued, dropped := c.QueueDepth()
+		stats = append(stats, QueueStat{
+			Addr:     c.conn.RemoteAddr().String(),
+			Username: value.(string),
+			Queued:   queued,
+			Dropped:  dropped,
+		})
+		return true
+	})
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Queued > stats[j].Queued })
+	return stats
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/protocol"
+)
+
+const (
+	maxReactions  = 20 // distinct emoji on one message
+	maxEmojiBytes = 32 // longest emoji or :shortcode:
+)
+
+// shortcodes maps common :shortcodes: to their emoji so ":+1:" and "👍"
+// count as the same reaction. Other shortcodes are kept as typed.
+var shortcodes = map[string]string{
+	":+1:":               "👍",
+	":thumbsup:":         "👍",
+	":-1:":               "👎",
+	":thumbsdown:":       "👎",
+	":heart:":            "❤️",
+	":joy:":              "😂",
+	":laughing:":         "😆",
+	":smile:":            "😄",
+	":tada:":             "🎉",
+	":eyes:":             "👀",
+	":fire:":             "🔥",
+	":rocket:":           "🚀",
+	":pray:":             "🙏",
+	":white_check_mark:": "✅",
+}
+
+// normalizeEmoji returns the reaction key for an emoji or :shortcode:.
+// Plain words are refused so reactions can't be used as a side channel
+// for chat.
+func normalizeEmoji(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if e, ok := shortcodes[strings.ToLower(s)]; ok {
+		return e, true
+	}
+	if s == "" || len(s) > maxEmojiBytes {
+		return "", false
+	}
+	if strings.ContainsFunc(s, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return "", false
+	}
+	if isASCII(s) && !isShortcode(s) {
+		return "", false
+	}
+	return s, true
+}
+
+func isASCII(s string) bool {
+	return !strings.ContainsFunc(s, func(r rune) bool { return r > unicode.MaxASCII })
+}
+
+// isShortcode reports whether s looks like :name:
+func isShortcode(s string) bool {
+	name, ok := strings.CutPrefix(s, ":")
+	if !ok {
+		return false
+	}
+	if name, ok = strings.CutSuffix(name, ":"); !ok || name == "" {
+		return false
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || strings.ContainsRune("_+-", r))
+	})
+}
+
+// addReaction returns reactions with username's emoji added, and whether
+// anything changed. The slices passed in are never modified, since they
+// may be shared with scrollback.
+func addReaction(reactions []protocol.Reaction, emoji, username string) ([]protocol.Reaction, bool) {
+	i := slices.IndexFunc(reactions, func(r protocol.Reaction) bool { return r.Emoji == emoji })
+	if i < 0 {
+		out := append(slices.Clone(reactions), protocol.Reaction{Emoji: emoji, Count: 1, Users: []string{username}})
+		return out, true
+	}
+	if slices.Contains(reactions[i].Users, username) {
+		return reactions, false
+	}
+	out := slices.Clone(reactions)
+	out[i].Users = append(slices.Clone(out[i].Users), username)
+	out[i].Count = len(out[i].Users)
+	return out, true
+}
+
+// removeReaction is addReaction in reverse; an emoji nobody uses any more
+// is dropped.
+func removeReaction(reactions []protocol.Reaction, emoji, username string) ([]protocol.Reaction, bool) {
+	i := slices.IndexFunc(reactions, func(r protocol.Reaction) bool { return r.Emoji == emoji })
+	if i < 0 || !slices.Contains(reactions[i].Users, username) {
+		return reactions, false
+	}
+	if len(reactions[i].Users) == 1 {
+		return slices.Delete(slices.Clone(reactions), i, i+1), true
+	}
+	out := slices.Clone(reactions)
+	out[i].Users = slices.DeleteFunc(slices.Clone(out[i].Users), func(u string) bool { return u == username })
+	out[i].Count = len(out[i].Users)
+	return out, true
+}
+
+// react adds or removes c's reaction on one of the room's messages and
+// tells the members the new counts.
+func (r *Room) react(c *Client, req protocol.WireMessage) {
+	emoji, ok := normalizeEmoji(req.Emoji)
+	if !ok {
+		c.ReplyError(req, protocol.ErrCodeInvalidPayload, fmt.Sprintf("%q is not an emoji or :shortcode:", req.Emoji))
+		return
+	}
+	msg, ok := r.lookup(req.MsgID)
+	if !ok || msg.Deleted {
+		c.ReplyError(req, protocol.ErrCodeMessageNotFound, fmt.Sprintf("no message %q in room %q", req.MsgID, r.Name))
+		return
+	}
+
+	var reactions []protocol.Reaction
+	var changed bool
+	if req.Type == protocol.TypeReact {
+		reactions, changed = addReaction(msg.Reactions, emoji, c.Username)
+		if len(reactions) > maxReactions {
+			c.ReplyError(req, protocol.ErrCodeInvalidPayload, fmt.Sprintf("a message can have at most %d different reactions", maxReactions))
+			return
+		}
+	} else {
+		reactions, changed = removeReaction(msg.Reactions, emoji, c.Username)
+	}
+	notice := protocol.NewReactionsNotification(r.Name, msg.MsgID, msg.Seq, reactions)
+	if !changed {
+		c.Reply(req, *notice) // reacting twice, or removing a reaction never made
+		return
+	}
+
+	err := r.store.SetReactions(r.Name, msg.MsgID, reactionsToStore(reactions))
+	if err != nil && !errors.Is(err, chatstore.ErrMessageNotFound) {
+		r.log.Error("store reactions failed", "msg_id", msg.MsgID, "err", err)
+		c.ReplyError(req, protocol.ErrCodeInternal, "failed to save reaction")
+		return
+	}
+	msg.Reactions = reactions
+	r.history.replace(msg)
+
+	r.Broadcast(*notice, c)
+	c.Reply(req, *notice)
+}
+
+func (h *Hub) handleReact(c *Client, msg protocol.WireMessage) {
+	if room, ok := h.memberRoom(c, msg); ok && h.allowRoom(c, msg) {
+		h.forward(room, opReact, c, msg)
+	}
+}
+
+func reactionsToStore(reactions []protocol.Reaction) []chatstore.Reaction {
+	if len(reactions) == 0 {
+		return nil
+	}
+	out := make([]chatstore.Reaction, len(reactions))
+	for i, r := range reactions {
+		out[i] = chatstore.Reaction{Emoji: r.Emoji, Users: r.Users}
+	}
+	return out
+}
+
+func reactionsFromStore(reactions []chatstore.Reaction) []protocol.Reaction {
+	if len(reactions) == 0 {
+		return nil
+	}
+	out := make([]protocol.Reaction, len(reactions))
+	for i, r := range reactions {
+		out[i] = protocol.Reaction{Emoji: r.Emoji, Count: len(r.Users), Users: r.Users}
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/danieljhkim/chat-server/internal/config"
+)
+
+// resumeSweepInterval is how often the hub expires unclaimed resume states
+const resumeSweepInterval = 5 * time.Second
+
+// resumeState is what a dropped connection leaves behind so a reconnect
+// with its resume token can rejoin the same rooms without the other
+// members seeing it leave and come back.
+type resumeState struct {
+	username string
+	rooms    []string
+	expires  time.Time
+}
+
+func newResumeToken() string {
+	return randomHex(16)
+}
+
+// newMessageID returns the server-assigned ID of a room message or DM.
+func newMessageID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// suspend takes c out of its rooms quietly and parks them under its resume
+// token for ResumeWindow. Returns false if there is nothing to park.
+func (h *Hub) suspend(c *Client) bool {
+	if c.resumeToken == "" || config.Get().ResumeWindow <= 0 {
+		return false
+	}
+	var rooms []string
+	for name, r := range h.memberships[c] {
+		r.post(roomOp{kind: opSuspend, client: c})
+		rooms = append(rooms, name)
+	}
+	if len(rooms) == 0 {
+		return true
+	}
+	h.resumes[c.resumeToken] = &resumeState{
+		username: c.Username,
+		rooms:    rooms,
+		expires:  time.Now().Add(config.Get().ResumeWindow),
+	}
+	h.log.Debug("session suspended", "username", c.Username, "rooms", rooms)
+	return true
+}
+
+// resume puts c back in the rooms parked under token, if token belongs to
+// the same user and has not expired. A token still held by a live
+// connection means the client noticed the drop before the server did; c
+// then takes over that connection's rooms.
+func (h *Hub) resume(c *Client, token string) {
+	if old := h.holder(c.Username, token); old != nil {
+		h.takeOver(c, old)
+		return
+	}
+	st, ok := h.resumes[token]
+	if !ok || st.username != c.Username {
+		return
+	}
+	delete(h.resumes, token)
+	for _, name := range st.rooms {
+		if r, ok := h.Rooms[name]; ok {
+			r.post(roomOp{kind: opResume, client: c})
+			h.addMembership(c, r)
+		}
+	}
+	h.log.Info("session resumed", "username", c.Username, "rooms", st.rooms)
+}
+
+// holder returns the live connection of username that was issued token, if any.
+func (h *Hub) holder(username, token string) *Client {
+	for cl := range h.users[username] {
+		if cl.resumeToken == token {
+			return cl
+		}
+	}
+	return nil
+}
+
+// takeOver moves old's room memberships to c without the other members
+// seeing a leave or join, then closes old.
+func (h *Hub) takeOver(c *Client, old *Client) {
+	var rooms []string
+	for name, r := range h.memberships[old] {
+		r.post(roomOp{kind: opSuspend, client: old})
+		r.post(roomOp{kind: opResume, client: c})
+		h.addMembership(c, r)
+		rooms = append(rooms, name)
+	}
+	delete(h.memberships, old)
+	old.resumeToken = "" // nothing left to park
+	h.removeClient(old)
+	h.log.Info("session taken over", "username", c.Username, "rooms", rooms)
+}
+
+// expireResumes announces the departure of users who did not come back in time.
+func (h *Hub) expireResumes(now time.Time) {
+	for token, st := range h.resumes {
+		if now.Before(st.expires) {
+			continue
+		}
+		delete(h.resumes, token)
+		for _, name := range st.rooms {
+			if r, ok := h.Rooms[name]; ok {
+				r.post(roomOp{kind: opGone, username: st.username})
+			}
+		}
+	}
+}
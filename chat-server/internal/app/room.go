@@ -1,29 +1,418 @@
 package app
 
-import "github.com/danieljhkim/chat-server/internal/protocol"
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
 
+	"github.com/danieljhkim/chat-server/internal/chatstore"
+	"github.com/danieljhkim/chat-server/internal/protocol"
+	"github.com/danieljhkim/chat-server/internal/security"
+)
+
+// roomQueueSize bounds a room's inbox; requests beyond it are refused. It
+// is allocated for every room, so keep it small.
+const roomQueueSize = 64
+
+// Room is an actor: the hub only hands it roomOps through its inbox, and
+// everything below Members is touched by the room's own goroutine alone.
 type Room struct {
-	Name    string
+	Name  string
+	inbox chan roomOp
+	done  chan struct{} // closed when Run returns
+	store chatstore.Store
+	log   *slog.Logger
+
+	mu      sync.Mutex     // guards backlog
+	backlog []roomOp       // posted ops waiting for inbox space, in order
+	feeding sync.WaitGroup // the goroutine moving backlog into the inbox
+
 	Members map[*Client]struct{}
+	history *history // recent room_msg/action messages for scrollback
+	seq     uint64   // Seq of the latest message
+}
+
+// roomOpKind says what a roomOp asks the room to do
+type roomOpKind int
+
+const (
+	opJoin    roomOpKind = iota // add client if new and confirm; msg is the join request
+	opLeave                     // remove client, tell the others, confirm
+	opPost                      // record and broadcast a room_msg/action
+	opAmend                     // edit or delete a recorded message
+	opReact                     // add or remove a reaction on a recorded message
+	opHistory                   // reply with scrollback or messages since msg.Since
+	opThread                    // reply with the thread msg.MsgID belongs to
+	opUsers                     // reply with the member list
+	opDrop                      // client disconnected: remove and tell the others
+	opSuspend                   // client disconnected but may resume: remove quietly
+	opResume                    // put a resumed client back quietly
+	opGone                      // resume window ran out: tell the others username left
+)
+
+type roomOp struct {
+	kind     roomOpKind
+	client   *Client
+	msg      protocol.WireMessage
+	username string // opGone only
 }
 
 // constructor
-func NewRoom(name string) *Room {
+func NewRoom(name string, historySize int, store chatstore.Store, log *slog.Logger) *Room {
 	return &Room{
 		Name:    name,
+		inbox:   make(chan roomOp, roomQueueSize),
+		done:    make(chan struct{}),
+		store:   store,
+		log:     log.With("room", name),
 		Members: make(map[*Client]struct{}),
+		history: newHistory(historySize),
+	}
+}
+
+// Run processes the inbox until the hub closes it.
+func (r *Room) Run() {
+	defer close(r.done)
+	for op := range r.inbox {
+		r.handle(op)
+	}
+}
+
+// tryPost queues op unless the room is backed up.
+func (r *Room) tryPost(op roomOp) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.backlog) > 0 {
+		return false // would overtake ops posted earlier
+	}
+	select {
+	case r.inbox <- op:
+		return true
+	default:
+		return false
+	}
+}
+
+// post queues op even when the inbox is full, without blocking the
+// caller. Used for membership changes the room must not miss; ops that
+// don't fit wait in the backlog and a goroutine feeds them in order.
+func (r *Room) post(op roomOp) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.backlog) == 0 {
+		select {
+		case r.inbox <- op:
+			return
+		default:
+		}
+	}
+	r.backlog = append(r.backlog, op)
+	if len(r.backlog) == 1 {
+		r.feeding.Add(1)
+		go r.feed()
+	}
+}
+
+// feed moves the backlog into the inbox, waiting for space, and exits once
+// it is empty.
+func (r *Room) feed() {
+	defer r.feeding.Done()
+	for {
+		r.mu.Lock()
+		op := r.backlog[0]
+		r.mu.Unlock()
+
+		r.inbox <- op
+
+		r.mu.Lock()
+		r.backlog[0] = roomOp{}
+		r.backlog = r.backlog[1:]
+		empty := len(r.backlog) == 0
+		r.mu.Unlock()
+		if empty {
+			return
+		}
+	}
+}
+
+// close closes the inbox once the backlog has been handed over, so Run
+// finishes everything posted. Nothing may be posted after.
+func (r *Room) close() {
+	r.feeding.Wait()
+	close(r.inbox)
+}
+
+func (r *Room) handle(op roomOp) {
+	c, msg := op.client, op.msg
+	switch op.kind {
+	case opJoin:
+		r.join(c, msg)
+	case opLeave:
+		r.Remove(c)
+		c.Reply(msg, *r.announceLeft(c.Username))
+	case opPost:
+		r.publish(c, msg)
+	case opAmend:
+		r.amend(c, msg)
+	case opReact:
+		r.react(c, msg)
+	case opHistory:
+		r.replyHistory(c, msg)
+	case opThread:
+		r.replyThread(c, msg)
+	case opUsers:
+		names := make([]string, 0, len(r.Members))
+		for cl := range r.Members {
+			names = append(names, cl.Username)
+		}
+		c.Reply(msg, protocol.WireMessage{Type: protocol.TypeUserList, Room: r.Name, Users: names})
+	case opDrop:
+		if r.Has(c) {
+			r.Remove(c)
+			r.announceLeft(c.Username)
+		}
+	case opSuspend:
+		r.Remove(c)
+	case opResume:
+		r.Add(c)
+	case opGone:
+		if !r.hasUser(op.username) { // not if they came back on another connection
+			r.announceLeft(op.username)
+		}
+	}
+}
+
+func (r *Room) join(c *Client, msg protocol.WireMessage) {
+	notice := protocol.NewUserJoinedNotification(r.Name, msg.Username, 0)
+	notice.Body = fmt.Sprintf("%s joined the room.", msg.Username)
+	if r.Has(c) {
+		// already a member: confirm to the caller only
+		notice.UserCount = r.Count()
+	} else {
+		r.Add(c)
+		notice.UserCount = r.Count()
+		r.Broadcast(*notice, c)
+	}
+	c.Reply(msg, *notice)
+
+	// scrollback follows the join notice so clients render it above live messages
+	if (msg.Since > 0 || msg.Limit > 0) && c.supports(protocol.CapHistory) {
+		r.replyHistory(c, msg)
+	}
+}
+
+// publish records and broadcasts a room_msg/action. Only the fields a
+// client may choose are copied from req; everything else is the server's.
+func (r *Room) publish(c *Client, req protocol.WireMessage) {
+	msg := protocol.WireMessage{
+		Type:     req.Type,
+		ID:       req.ID,
+		Room:     r.Name,
+		Username: c.Username,
+		Body:     req.Body,
+		ParentID: req.ParentID,
+	}
+	var quote *protocol.Quote
+	if msg.ParentID != "" {
+		parent, ok := r.replyParent(c, &msg)
+		if !ok {
+			return
+		}
+		quote = quoteOf(parent)
+	}
+	msg.Body = security.SanitizeInput(msg.Body)
+	msg.Mentions = r.parseMentions(msg.Body)
+	// identity and ordering come from the server, whatever the client sent
+	msg.MsgID = newMessageID()
+	msg.Timestamp = time.Now()
+	msg.Seq = r.NextSeq()
+	if err := r.store.AppendRoomMessage(roomMessageFromWire(msg)); err != nil {
+		r.log.Error("store room message failed", "err", err)
+	}
+	r.recordMentions(msg, msg.Mentions)
+	out := msg
+	out.ID = "" // request IDs are private to the sender
+	r.Record(out)
+	out.Quote = quote
+	r.Broadcast(out, c)
+	c.Reply(msg, out)
+}
+
+// announceLeft tells the members that username is gone.
+func (r *Room) announceLeft(username string) *protocol.WireMessage {
+	notice := protocol.NewUserLeftNotification(r.Name, username, r.Count())
+	notice.Body = fmt.Sprintf("%s left the room.", username)
+	r.Broadcast(*notice, nil)
+	return notice
+}
+
+func (r *Room) replyHistory(c *Client, msg protocol.WireMessage) {
+	if msg.Since == 0 {
+		c.Reply(msg, *protocol.NewHistoryResponse(r.Name, 0, r.withQuotes(r.Recent(msg.Limit))))
+		return
 	}
+	missed := r.messagesSince(msg.Since)
+	if msg.Limit > 0 && len(missed) > msg.Limit {
+		missed = missed[len(missed)-msg.Limit:]
+	}
+	c.Reply(msg, *protocol.NewHistoryResponse(r.Name, msg.Since, r.withQuotes(missed)))
+}
+
+// messagesSince returns messages after seq, from scrollback when it
+// reaches back far enough and from the store otherwise.
+func (r *Room) messagesSince(seq uint64) []protocol.WireMessage {
+	if msgs, ok := r.Since(seq); ok {
+		return msgs
+	}
+	stored, err := r.store.RoomHistory(r.Name, 0)
+	if err != nil {
+		r.log.Error("load room history failed", "err", err)
+		msgs, _ := r.Since(seq)
+		return msgs
+	}
+	var msgs []protocol.WireMessage
+	for _, m := range stored {
+		if m.Seq > seq {
+			msgs = append(msgs, wireFromRoomMessage(m))
+		}
+	}
+	return msgs
 }
 
 func (r *Room) Add(c *Client)    { r.Members[c] = struct{}{} }
 func (r *Room) Remove(c *Client) { delete(r.Members, c) }
+func (r *Room) Count() int       { return len(r.Members) }
+
+func (r *Room) Has(c *Client) bool {
+	_, ok := r.Members[c]
+	return ok
+}
+
+// hasUser reports whether any member connection belongs to username
+func (r *Room) hasUser(username string) bool {
+	for m := range r.Members {
+		if m.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// NextSeq allocates the sequence number for a new message
+func (r *Room) NextSeq() uint64 {
+	r.seq++
+	return r.seq
+}
+
+// Record keeps msg in the room's scrollback
+func (r *Room) Record(msg protocol.WireMessage) {
+	if msg.Seq > r.seq {
+		r.seq = msg.Seq
+	}
+	r.history.add(msg)
+}
+
+// Recent returns up to n of the latest recorded messages, oldest first
+func (r *Room) Recent(n int) []protocol.WireMessage { return r.history.last(n) }
+
+// Since returns the recorded messages after seq, oldest first. ok is false
+// when the scrollback no longer reaches back to seq.
+func (r *Room) Since(seq uint64) (msgs []protocol.WireMessage, ok bool) {
+	if seq >= r.seq {
+		return nil, true
+	}
+	all := r.history.last(0)
+	if len(all) == 0 || all[0].Seq > seq+1 {
+		return nil, false
+	}
+	for i, m := range all {
+		if m.Seq > seq {
+			return all[i:], true
+		}
+	}
+	return nil, true
+}
 
 // Broadcast sends msg to every member, optional ‘skip’ (e.g. sender)
+// The message is encoded once and the frame shared by all recipients.
 func (r *Room) Broadcast(msg protocol.WireMessage, skip *Client) {
+	frame, err := encodeFrame(msg)
+	if err != nil {
+		r.log.Error("encode broadcast failed", "type", msg.Type, "err", err)
+		return
+	}
 	for m := range r.Members {
 		if m == skip {
 			continue
 		}
-		m.Send(msg)
+		m.SendFrame(frame)
+	}
+}
+
+// history is a fixed-size ring buffer of messages
+type history struct {
+	buf   []protocol.WireMessage
+	start int // index of the oldest message
+	n     int
+}
+
+func newHistory(size int) *history {
+	if size < 0 {
+		size = 0
+	}
+	return &history{buf: make([]protocol.WireMessage, size)}
+}
+
+func (h *history) add(msg protocol.WireMessage) {
+	if len(h.buf) == 0 {
+		return
+	}
+	if h.n < len(h.buf) {
+		h.buf[(h.start+h.n)%len(h.buf)] = msg
+		h.n++
+		return
+	}
+	h.buf[h.start] = msg // overwrite the oldest
+	h.start = (h.start + 1) % len(h.buf)
+}
+
+// replace swaps in msg for the recorded message with the same MsgID and
+// reports whether there was one.
+func (h *history) replace(msg protocol.WireMessage) bool {
+	if i := h.index(msg.MsgID); i >= 0 {
+		h.buf[i] = msg
+		return true
+	}
+	return false
+}
+
+// find returns the recorded message with the given MsgID.
+func (h *history) find(id string) (protocol.WireMessage, bool) {
+	if i := h.index(id); i >= 0 {
+		return h.buf[i], true
+	}
+	return protocol.WireMessage{}, false
+}
+
+// index returns the buf index of the message with the given MsgID, or -1.
+func (h *history) index(id string) int {
+	for k := h.n - 1; k >= 0; k-- {
+		i := (h.start + k) % len(h.buf)
+		if h.buf[i].MsgID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *history) last(n int) []protocol.WireMessage {
+	if n <= 0 || n > h.n {
+		n = h.n
+	}
+	out := make([]protocol.WireMessage, n)
+	for i := 0; i < n; i++ {
+		out[i] = h.buf[(h.start+h.n-n+i)%len(h.buf)]
 	}
+	return out
 }
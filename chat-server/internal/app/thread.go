@@ -0,0 +1,78 @@
+package app
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/danieljhkim/chat-server/internal/protocol"
+)
+
+// quoteLen is how many characters of the parent a reply quotes
+const quoteLen = 80
+
+// replyParent resolves the message a reply answers and sets the reply's
+// ThreadID. Replies to unknown or deleted messages are refused.
+func (r *Room) replyParent(c *Client, msg *protocol.WireMessage) (protocol.WireMessage, bool) {
+	parent, ok := r.lookup(msg.ParentID)
+	if !ok || parent.Deleted {
+		c.ReplyError(*msg, protocol.ErrCodeMessageNotFound, fmt.Sprintf("cannot reply: no message %q in room %q", msg.ParentID, r.Name))
+		return protocol.WireMessage{}, false
+	}
+	msg.ThreadID = cmp.Or(parent.ThreadID, parent.MsgID)
+	return parent, true
+}
+
+// quoteOf returns the snippet of parent shown with its replies.
+func quoteOf(parent protocol.WireMessage) *protocol.Quote {
+	body := []rune(parent.Body)
+	if len(body) > quoteLen {
+		body = append(body[:quoteLen-1], '…')
+	}
+	return &protocol.Quote{
+		Username: parent.Username,
+		Seq:      parent.Seq,
+		Body:     string(body),
+		Deleted:  parent.Deleted,
+	}
+}
+
+// withQuotes attaches a quote of the current parent to each reply in msgs.
+// Quotes are never stored, so edits and deletes of the parent show up.
+func (r *Room) withQuotes(msgs []protocol.WireMessage) []protocol.WireMessage {
+	for i := range msgs {
+		if msgs[i].ParentID == "" || msgs[i].Deleted {
+			continue
+		}
+		if parent, ok := r.lookup(msgs[i].ParentID); ok {
+			msgs[i].Quote = quoteOf(parent)
+		}
+	}
+	return msgs
+}
+
+// replyThread sends the whole thread the requested message belongs to.
+func (r *Room) replyThread(c *Client, req protocol.WireMessage) {
+	msg, ok := r.lookup(req.MsgID)
+	if !ok {
+		c.ReplyError(req, protocol.ErrCodeMessageNotFound, fmt.Sprintf("no message %q in room %q", req.MsgID, r.Name))
+		return
+	}
+	threadID := cmp.Or(msg.ThreadID, msg.MsgID)
+	stored, err := r.store.ThreadHistory(r.Name, threadID)
+	if err != nil {
+		r.log.Error("load thread failed", "thread_id", threadID, "err", err)
+		c.ReplyError(req, protocol.ErrCodeInternal, "failed to load thread")
+		return
+	}
+	thread := make([]protocol.WireMessage, 0, len(stored))
+	for _, m := range stored {
+		thread = append(thread, wireFromRoomMessage(m))
+	}
+	c.Reply(req, *protocol.NewThreadResponse(r.Name, threadID, r.withQuotes(thread)))
+}
+
+func (h *Hub) handleThread(c *Client, msg protocol.WireMessage) {
+	if room, ok := h.lookupRoom(c, msg); ok {
+		h.forward(room, opThread, c, msg)
+	}
+}
@@ -0,0 +1,487 @@
+package chatstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	logFile      = "log.jsonl"
+
+	// redactDelay batches the snapshots that purge edited and deleted
+	// text, so a burst of edits costs one rewrite
+	redactDelay = time.Second
+)
+
+// Log operations, one per mutating Store method
+const (
+	opAddDM         = "add_dm"
+	opEnqueueDM     = "enqueue_dm"
+	opTakePending   = "take_pending"
+	opMarkDMsRead   = "mark_dms_read"
+	opSaveRoom      = "save_room"
+	opRoomMessage   = "room_msg"
+	opUpdateRoomMsg = "update_room_msg"
+	opSetReactions  = "set_reactions"
+	opUpdateDM      = "update_dm"
+	opDeleteDM      = "delete_dm"
+	opAddMention    = "add_mention"
+	opTakeMentions  = "take_mentions"
+	opCreateUser    = "create_user"
+	opAddUserToken  = "add_user_token"
+)
+
+// logEntry is one line of log.jsonl
+type logEntry struct {
+	Seq       uint64       `json:"seq,omitempty"` // numbers entries across snapshots
+	Op        string       `json:"op"`
+	DM        *DM          `json:"dm,omitempty"`
+	Message   *RoomMessage `json:"message,omitempty"`
+	User      *User        `json:"user,omitempty"`
+	Mention   *Mention     `json:"mention,omitempty"`
+	Username  string       `json:"username,omitempty"`
+	Room      string       `json:"room,omitempty"`
+	TokenHash string       `json:"token_hash,omitempty"`
+	ID        string       `json:"id,omitempty"` // message ID for delete_dm and set_reactions
+	Reactions []Reaction   `json:"reactions,omitempty"`
+}
+
+// snapshot is the full state written to snapshot.json
+type snapshot struct {
+	DMs      map[string][]DM          `json:"dms"`
+	Pending  map[string][]DM          `json:"pending"`
+	Rooms    map[string][]RoomMessage `json:"rooms"`
+	Users    map[string]*User         `json:"users"`
+	Mentions map[string][]Mention     `json:"mentions"`
+	LogSeq   uint64                   `json:"log_seq,omitempty"` // last log entry included
+}
+
+// FileStore serves reads from memory and makes every write durable in an
+// append-only JSONL log. The log is folded into a snapshot every
+// snapshotEvery entries and on Close.
+type FileStore struct {
+	*MemoryStore
+
+	dir           string
+	snapshotEvery int
+	fsync         bool // sync the log after every append
+
+	mu       sync.Mutex // orders append + apply so the log matches memory
+	log      *os.File
+	seq      uint64      // Seq of the last log entry
+	entries  int         // entries written since the last snapshot
+	purge    *time.Timer // pending snapshot after a redact
+	purgeErr error       // why the last pending snapshot failed
+}
+
+// OpenFileStore loads dir/snapshot.json, replays dir/log.jsonl and keeps the
+// log open for appends. With fsync, every append reaches the disk before
+// it returns; otherwise the OS flushes the log when it sees fit.
+func OpenFileStore(dir string, snapshotEvery, historyLimit int, fsync bool) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("storage path is required for the file driver")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+
+	s := &FileStore{
+		MemoryStore:   NewMemoryStore(historyLimit),
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+		fsync:         fsync,
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	valid, err := s.replayLog()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open storage log: %w", err)
+	}
+	// drop a torn last line so the next entry doesn't run on from it
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("truncate torn storage log: %w", err)
+	}
+	s.log = f
+	return s, nil
+}
+
+func (s *FileStore) AddDM(dm DM) error {
+	return s.write(logEntry{Op: opAddDM, DM: &dm})
+}
+
+func (s *FileStore) EnqueueDM(dm DM) error {
+	return s.write(logEntry{Op: opEnqueueDM, DM: &dm})
+}
+
+func (s *FileStore) TakePendingDMs(username string) ([]DM, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs, _ := s.MemoryStore.TakePendingDMs(username)
+	if len(msgs) == 0 {
+		return nil, nil
+	}
+	if err := s.append(logEntry{Op: opTakePending, Username: username}); err != nil {
+		return msgs, err
+	}
+	return msgs, s.snapshotIfDue()
+}
+
+func (s *FileStore) MarkDMsRead(username string) error {
+	return s.write(logEntry{Op: opMarkDMsRead, Username: username})
+}
+
+func (s *FileStore) SaveRoom(name string) error {
+	return s.write(logEntry{Op: opSaveRoom, Room: name})
+}
+
+func (s *FileStore) AppendRoomMessage(msg RoomMessage) error {
+	return s.write(logEntry{Op: opRoomMessage, Message: &msg})
+}
+
+func (s *FileStore) UpdateRoomMessage(msg RoomMessage) error {
+	return s.redact(logEntry{Op: opUpdateRoomMsg, Message: &msg})
+}
+
+// SetReactions is an ordinary write: reactions are not text worth purging.
+func (s *FileStore) SetReactions(room, id string, reactions []Reaction) error {
+	return s.write(logEntry{Op: opSetReactions, Room: room, ID: id, Reactions: reactions})
+}
+
+func (s *FileStore) UpdateDM(dm DM) error {
+	return s.redact(logEntry{Op: opUpdateDM, DM: &dm})
+}
+
+func (s *FileStore) DeleteDM(id string) error {
+	return s.redact(logEntry{Op: opDeleteDM, ID: id})
+}
+
+func (s *FileStore) AddMention(m Mention) error {
+	return s.write(logEntry{Op: opAddMention, Mention: &m})
+}
+
+func (s *FileStore) TakeMentions(username string) ([]Mention, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mentions, _ := s.MemoryStore.TakeMentions(username)
+	if len(mentions) == 0 {
+		return nil, nil
+	}
+	if err := s.append(logEntry{Op: opTakeMentions, Username: username}); err != nil {
+		return mentions, err
+	}
+	return mentions, s.snapshotIfDue()
+}
+
+func (s *FileStore) CreateUser(u User) error {
+	return s.write(logEntry{Op: opCreateUser, User: &u})
+}
+
+func (s *FileStore) AddUserToken(username, tokenHash string) error {
+	return s.write(logEntry{Op: opAddUserToken, Username: username, TokenHash: tokenHash})
+}
+
+// Close writes a final snapshot and closes the log.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	if s.purge != nil {
+		s.purge.Stop()
+		s.purge = nil
+	}
+	err := s.snapshot()
+	if cerr := s.log.Close(); err == nil {
+		err = cerr
+	}
+	s.log = nil
+	return err
+}
+
+// write appends e to the log, then applies it to memory, so memory is
+// never ahead of what is on disk. An entry memory refuses, like a taken
+// username, is refused again on replay.
+func (s *FileStore) write(e logEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(e); err != nil {
+		return err
+	}
+	if err := s.apply(e); err != nil {
+		return err
+	}
+	return s.snapshotIfDue()
+}
+
+// redact is write for edits and deletes. The text they replace is still
+// in the log and the last snapshot, so a snapshot is scheduled to purge
+// it; redacts within redactDelay of each other share one.
+func (s *FileStore) redact(e logEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(e); err != nil {
+		return err
+	}
+	if err := s.apply(e); err != nil {
+		return err
+	}
+	if err := s.snapshotIfDue(); err != nil {
+		return err
+	}
+	if s.purge == nil && s.entries > 0 {
+		s.purge = time.AfterFunc(redactDelay, s.purgeNow)
+	}
+	err := s.purgeErr
+	s.purgeErr = nil
+	return err
+}
+
+// purgeNow runs the snapshot redact scheduled. A failure is reported by
+// the next redact, which schedules another try.
+func (s *FileStore) purgeNow() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.purge == nil {
+		return // Close or an earlier snapshot got there first
+	}
+	s.purge = nil
+	if err := s.snapshot(); err != nil {
+		s.purgeErr = fmt.Errorf("purge redacted text: %w", err)
+	}
+}
+
+// apply replays e against the in-memory state.
+func (s *FileStore) apply(e logEntry) error {
+	m := s.MemoryStore
+	switch e.Op {
+	case opAddDM:
+		return m.AddDM(*e.DM)
+	case opEnqueueDM:
+		return m.EnqueueDM(*e.DM)
+	case opTakePending:
+		_, err := m.TakePendingDMs(e.Username)
+		return err
+	case opMarkDMsRead:
+		return m.MarkDMsRead(e.Username)
+	case opSaveRoom:
+		return m.SaveRoom(e.Room)
+	case opRoomMessage:
+		return m.AppendRoomMessage(*e.Message)
+	case opUpdateRoomMsg:
+		return m.UpdateRoomMessage(*e.Message)
+	case opSetReactions:
+		return m.SetReactions(e.Room, e.ID, e.Reactions)
+	case opUpdateDM:
+		return m.UpdateDM(*e.DM)
+	case opDeleteDM:
+		return m.DeleteDM(e.ID)
+	case opAddMention:
+		return m.AddMention(*e.Mention)
+	case opTakeMentions:
+		_, err := m.TakeMentions(e.Username)
+		return err
+	case opCreateUser:
+		return m.CreateUser(*e.User)
+	case opAddUserToken:
+		return m.AddUserToken(e.Username, e.TokenHash)
+	default:
+		return fmt.Errorf("unknown storage op %q", e.Op)
+	}
+}
+
+// append writes e to the log under the next Seq.
+func (s *FileStore) append(e logEntry) error {
+	if s.log == nil {
+		return errors.New("store is closed")
+	}
+	e.Seq = s.seq + 1
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("append storage log: %w", err)
+	}
+	if s.fsync {
+		if err := s.log.Sync(); err != nil {
+			return fmt.Errorf("sync storage log: %w", err)
+		}
+	}
+	s.seq++
+	s.entries++
+	return nil
+}
+
+// snapshotIfDue snapshots once snapshotEvery entries have been appended.
+// Callers apply their entry first so the snapshot includes it.
+func (s *FileStore) snapshotIfDue() error {
+	if s.snapshotEvery > 0 && s.entries >= s.snapshotEvery {
+		return s.snapshot()
+	}
+	return nil
+}
+
+// snapshot writes the full state atomically and truncates the log. The
+// snapshot records the Seq of the last entry it includes, so a crash
+// between the rename and the truncate leaves log entries replay knows to
+// skip.
+func (s *FileStore) snapshot() error {
+	m := s.MemoryStore
+	m.mu.RLock()
+	data, err := json.Marshal(snapshot{
+		DMs:      m.dms,
+		Pending:  m.pending,
+		Rooms:    m.rooms,
+		Users:    m.users,
+		Mentions: m.mentions,
+		LogSeq:   s.seq,
+	})
+	m.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.dir, snapshotFile+".tmp")
+	if err := writeSynced(tmp, data); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, snapshotFile)); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("sync storage dir: %w", err)
+	}
+	if err := s.log.Truncate(0); err != nil {
+		return fmt.Errorf("truncate storage log: %w", err)
+	}
+	s.entries = 0
+	if s.purge != nil {
+		s.purge.Stop()
+		s.purge = nil
+	}
+	return nil
+}
+
+// writeSynced is os.WriteFile that also syncs the file before closing it.
+func writeSynced(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir makes a rename in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+	m := s.MemoryStore
+	if snap.DMs != nil {
+		m.dms = snap.DMs
+	}
+	if snap.Pending != nil {
+		m.pending = snap.Pending
+	}
+	if snap.Rooms != nil {
+		m.rooms = snap.Rooms
+	}
+	if snap.Users != nil {
+		m.users = snap.Users
+	}
+	if snap.Mentions != nil {
+		m.mentions = snap.Mentions
+	}
+	s.seq = snap.LogSeq
+	return nil
+}
+
+// replayLog applies entries written after the last snapshot and returns
+// the length of the log up to the end of its last complete line. A torn
+// final line from a crash mid-write is ignored, and so are entries the
+// snapshot already includes.
+func (s *FileStore) replayLog() (int64, error) {
+	f, err := os.Open(filepath.Join(s.dir, logFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open storage log: %w", err)
+	}
+	defer f.Close()
+
+	var valid int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil // partial or empty last line
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read storage log: %w", err)
+		}
+		var e logEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return 0, fmt.Errorf("decode storage log entry %d: %w", s.entries+1, err)
+		}
+		valid += int64(len(line))
+		s.entries++
+		// entries without a Seq predate it and are always replayed
+		if e.Seq != 0 && e.Seq <= s.seq {
+			continue
+		}
+		if e.Seq != 0 {
+			s.seq = e.Seq
+		}
+		// an entry that failed when written fails the same way again
+		_ = s.apply(e)
+	}
+}
@@ -0,0 +1,103 @@
+package chatstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// crash closes s's log the way a killed process would: no final snapshot.
+func crash(t *testing.T, s *FileStore) {
+	t.Helper()
+	if err := s.log.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.log = nil
+}
+
+func openTestStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	s, err := OpenFileStore(dir, 0, 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func roomMessage(body string, seq uint64) RoomMessage {
+	return RoomMessage{Type: "room_msg", Room: "general", Username: "alice", Body: body, Seq: seq, Timestamp: time.Now()}
+}
+
+func TestFileStoreTornLogTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	if err := s.AppendRoomMessage(roomMessage("one", 1)); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+
+	// a crash mid-write leaves half a line behind
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"op":"room_msg","message":{"bo`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	s = openTestStore(t, dir)
+	if err := s.AppendRoomMessage(roomMessage("two", 2)); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+
+	s = openTestStore(t, dir)
+	defer s.Close()
+	msgs, err := s.RoomHistory("general", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Body != "one" || msgs[1].Body != "two" {
+		t.Fatalf("history after two crashes = %+v, want one and two", msgs)
+	}
+}
+
+func TestFileStoreSnapshotWithStaleLog(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir)
+	for i, body := range []string{"one", "two"} {
+		if err := s.AppendRoomMessage(roomMessage(body, uint64(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash after the snapshot was renamed but before the log was
+	// truncated leaves both behind
+	if err := os.WriteFile(filepath.Join(dir, logFile), log, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s = openTestStore(t, dir)
+	if err := s.AppendRoomMessage(roomMessage("three", 3)); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+
+	s = openTestStore(t, dir)
+	defer s.Close()
+	msgs, err := s.RoomHistory("general", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("got %d messages after replaying a stale log, want 3: %+v", len(msgs), msgs)
+	}
+}
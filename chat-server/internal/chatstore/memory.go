@@ -0,0 +1,350 @@
+package chatstore
+
+import (
+	"sort"
+	"sync"
+)
+
+// mentionLimit is how many unread mentions are kept per user; older ones
+// are dropped
+const mentionLimit = 100
+
+// MemoryStore keeps everything in process memory; state is lost on restart.
+type MemoryStore struct {
+	dms      map[string][]DM // Key is recipient, value is slice of messages
+	pending  map[string][]DM // Key is recipient, value is DMs not yet delivered live
+	rooms    map[string][]RoomMessage
+	users    map[string]*User
+	mentions map[string][]Mention // Key is the mentioned user
+
+	historyLimit int
+	mu           sync.RWMutex
+}
+
+// NewMemoryStore keeps at most historyLimit messages per room (0 = unbounded)
+func NewMemoryStore(historyLimit int) *MemoryStore {
+	return &MemoryStore{
+		dms:          make(map[string][]DM),
+		pending:      make(map[string][]DM),
+		rooms:        make(map[string][]RoomMessage),
+		users:        make(map[string]*User),
+		mentions:     make(map[string][]Mention),
+		historyLimit: historyLimit,
+	}
+}
+
+// AddDM stores a new direct message for its recipient
+func (s *MemoryStore) AddDM(dm DM) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dms[dm.Recipient] = append(s.dms[dm.Recipient], dm)
+	return nil
+}
+
+// GetDMs retrieves all messages received by a user
+func (s *MemoryStore) GetDMs(username string) ([]DM, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Return a copy to prevent external modification
+	result := make([]DM, len(s.dms[username]))
+	copy(result, s.dms[username])
+	return result, nil
+}
+
+// MarkDMsRead marks every message received by username as read
+func (s *MemoryStore) MarkDMsRead(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.dms[username] {
+		s.dms[username][i].Read = true
+	}
+	return nil
+}
+
+// UnreadDMCount returns the number of unread messages for a user
+func (s *MemoryStore) UnreadDMCount(username string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, dm := range s.dms[username] {
+		if !dm.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// EnqueueDM holds a DM for an offline recipient until TakePendingDMs is called
+func (s *MemoryStore) EnqueueDM(dm DM) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending[dm.Recipient] = append(s.pending[dm.Recipient], dm)
+	return nil
+}
+
+// TakePendingDMs returns and clears the queued DMs for a user
+func (s *MemoryStore) TakePendingDMs(username string) ([]DM, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msgs := s.pending[username]
+	delete(s.pending, username)
+	return msgs, nil
+}
+
+// GetDM returns the direct message with the given ID
+func (s *MemoryStore) GetDM(id string) (DM, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, dms := range s.dms {
+		if i := indexDM(dms, id); i >= 0 {
+			return dms[i], nil
+		}
+	}
+	return DM{}, ErrMessageNotFound
+}
+
+// UpdateDM replaces the stored copy of dm, and the queued one if it has
+// not been delivered yet
+func (s *MemoryStore) UpdateDM(dm DM) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := indexDM(s.dms[dm.Recipient], dm.ID)
+	if i < 0 {
+		return ErrMessageNotFound
+	}
+	s.dms[dm.Recipient][i] = dm
+	if j := indexDM(s.pending[dm.Recipient], dm.ID); j >= 0 {
+		s.pending[dm.Recipient][j] = dm
+	}
+	return nil
+}
+
+// DeleteDM removes a direct message, and its queued copy if undelivered
+func (s *MemoryStore) DeleteDM(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for recipient, dms := range s.dms {
+		i := indexDM(dms, id)
+		if i < 0 {
+			continue
+		}
+		s.dms[recipient] = append(dms[:i:i], dms[i+1:]...)
+		if j := indexDM(s.pending[recipient], id); j >= 0 {
+			pending := s.pending[recipient]
+			s.pending[recipient] = append(pending[:j:j], pending[j+1:]...)
+		}
+		return nil
+	}
+	return ErrMessageNotFound
+}
+
+func indexDM(dms []DM, id string) int {
+	for i := range dms {
+		if dms[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// SaveRoom records a room so it survives with its history
+func (s *MemoryStore) SaveRoom(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rooms[name]; !ok {
+		s.rooms[name] = nil
+	}
+	return nil
+}
+
+// ListRooms returns every saved room name, sorted
+func (s *MemoryStore) ListRooms() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.rooms))
+	for name := range s.rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// AppendRoomMessage adds msg to its room's history, trimming the oldest
+// messages beyond the history limit
+func (s *MemoryStore) AppendRoomMessage(msg RoomMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := append(s.rooms[msg.Room], msg)
+	if s.historyLimit > 0 && len(history) > s.historyLimit {
+		history = append([]RoomMessage(nil), history[len(history)-s.historyLimit:]...)
+	}
+	s.rooms[msg.Room] = history
+	return nil
+}
+
+// RoomHistory returns up to limit of the most recent messages, oldest first
+func (s *MemoryStore) RoomHistory(room string, limit int) ([]RoomMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := s.rooms[room]
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	result := make([]RoomMessage, len(history))
+	copy(result, history)
+	return result, nil
+}
+
+// ThreadHistory returns the thread's first message, if still kept, and
+// every reply in it, oldest first
+func (s *MemoryStore) ThreadHistory(room, threadID string) ([]RoomMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var thread []RoomMessage
+	for _, m := range s.rooms[room] {
+		if m.ID == threadID || m.ThreadID == threadID {
+			thread = append(thread, m)
+		}
+	}
+	return thread, nil
+}
+
+// GetRoomMessage returns the message with the given ID from room's history
+func (s *MemoryStore) GetRoomMessage(room, id string) (RoomMessage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := indexRoomMessage(s.rooms[room], id); i >= 0 {
+		return s.rooms[room][i], nil
+	}
+	return RoomMessage{}, ErrMessageNotFound
+}
+
+// UpdateRoomMessage replaces the message with msg.ID in its room's history
+func (s *MemoryStore) UpdateRoomMessage(msg RoomMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := indexRoomMessage(s.rooms[msg.Room], msg.ID)
+	if i < 0 {
+		return ErrMessageNotFound
+	}
+	s.rooms[msg.Room][i] = msg
+	return nil
+}
+
+// SetReactions replaces the reactions on a message in room's history
+func (s *MemoryStore) SetReactions(room, id string, reactions []Reaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := indexRoomMessage(s.rooms[room], id)
+	if i < 0 {
+		return ErrMessageNotFound
+	}
+	s.rooms[room][i].Reactions = reactions
+	return nil
+}
+
+// indexRoomMessage searches newest first, where edits usually land
+func indexRoomMessage(history []RoomMessage, id string) int {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddMention stores a mention until TakeMentions is called, keeping at
+// most mentionLimit per user
+func (s *MemoryStore) AddMention(m Mention) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mentions := append(s.mentions[m.Username], m)
+	if len(mentions) > mentionLimit {
+		mentions = append([]Mention(nil), mentions[len(mentions)-mentionLimit:]...)
+	}
+	s.mentions[m.Username] = mentions
+	return nil
+}
+
+// TakeMentions returns and clears the stored mentions of a user
+func (s *MemoryStore) TakeMentions(username string) ([]Mention, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mentions := s.mentions[username]
+	delete(s.mentions, username)
+	return mentions, nil
+}
+
+// MentionCount returns the number of stored mentions of a user
+func (s *MemoryStore) MentionCount(username string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.mentions[username]), nil
+}
+
+// CreateUser stores a new account
+func (s *MemoryStore) CreateUser(u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[u.Username]; ok {
+		return ErrUserExists
+	}
+	if u.TokenHashes == nil {
+		u.TokenHashes = make(map[string]struct{})
+	}
+	s.users[u.Username] = &u
+	return nil
+}
+
+// GetUser returns a copy of the account for username
+func (s *MemoryStore) GetUser(username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	c := *u
+	c.TokenHashes = make(map[string]struct{}, len(u.TokenHashes))
+	for h := range u.TokenHashes {
+		c.TokenHashes[h] = struct{}{}
+	}
+	return c, nil
+}
+
+// AddUserToken records a newly issued session token hash
+func (s *MemoryStore) AddUserToken(username, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	u.TokenHashes[tokenHash] = struct{}{}
+	return nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
@@ -0,0 +1,17 @@
+package chatstore
+
+import (
+	"time"
+)
+
+// Mention records that a user was @mentioned in a room message while away
+// from the room. Only a reference is kept, so edits and deletes of the
+// message apply to it too.
+type Mention struct {
+	Username  string    `json:"username"` // who was mentioned
+	Room      string    `json:"room"`
+	MsgID     string    `json:"msg_id"`
+	Seq       uint64    `json:"seq,omitempty"`
+	From      string    `json:"from"` // author of the message
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,103 @@
+package chatstore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrUserExists         = errors.New("username is already taken")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidCredentials = errors.New("invalid username or credentials")
+	ErrMessageNotFound    = errors.New("message not found")
+)
+
+// Storage drivers selectable from config.yaml
+const (
+	DriverMemory = "memory"
+	DriverFile   = "file"
+)
+
+// RoomMessage is a room_msg or action kept in a room's history
+type RoomMessage struct {
+	Type      string     `json:"type"`
+	Room      string     `json:"room"`
+	Username  string     `json:"username"`
+	Body      string     `json:"body"`
+	Timestamp time.Time  `json:"timestamp"`
+	Seq       uint64     `json:"seq,omitempty"` // per-room sequence number
+	ID        string     `json:"id,omitempty"`  // server-assigned message ID
+	Edited    bool       `json:"edited,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`   // tombstone: body cleared, seq kept
+	ParentID  string     `json:"parent_id,omitempty"` // message this replies to
+	ThreadID  string     `json:"thread_id,omitempty"` // first message of the thread
+	Mentions  []string   `json:"mentions,omitempty"`  // @mentioned users
+	Reactions []Reaction `json:"reactions,omitempty"` // in order of first use
+}
+
+// Reaction is one emoji on a room message and the users who reacted with it
+type Reaction struct {
+	Emoji string   `json:"emoji"`
+	Users []string `json:"users"`
+}
+
+// Store persists DMs, rooms, room history and users.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Direct messages
+	AddDM(dm DM) error
+	GetDMs(username string) ([]DM, error)
+	MarkDMsRead(username string) error
+	UnreadDMCount(username string) (int, error)
+	EnqueueDM(dm DM) error                        // hold for an offline recipient
+	TakePendingDMs(username string) ([]DM, error) // return and clear held DMs
+
+	// Rooms and history
+	SaveRoom(name string) error
+	ListRooms() ([]string, error)
+	AppendRoomMessage(msg RoomMessage) error
+	RoomHistory(room string, limit int) ([]RoomMessage, error)  // oldest first
+	ThreadHistory(room, threadID string) ([]RoomMessage, error) // root and replies, oldest first
+
+	// Edits, deletes and reactions by message ID; ErrMessageNotFound if unknown
+	GetRoomMessage(room, id string) (RoomMessage, error)
+	UpdateRoomMessage(msg RoomMessage) error // replace the message with msg.ID
+	SetReactions(room, id string, reactions []Reaction) error
+	GetDM(id string) (DM, error)
+	UpdateDM(dm DM) error     // replace the DM with dm.ID, including while pending
+	DeleteDM(id string) error // remove the DM, including while pending
+
+	// Mentions of users who were not in the room
+	AddMention(m Mention) error
+	TakeMentions(username string) ([]Mention, error) // return and clear, oldest first
+	MentionCount(username string) (int, error)
+
+	// Users
+	CreateUser(u User) error // ErrUserExists if taken
+	GetUser(username string) (User, error)
+	AddUserToken(username, tokenHash string) error
+
+	Close() error
+}
+
+// Options configures Open
+type Options struct {
+	Driver           string // DriverMemory or DriverFile
+	Path             string // directory for DriverFile
+	SnapshotEvery    int    // log entries between snapshots for DriverFile
+	Fsync            bool   // sync the log after every write for DriverFile
+	RoomHistoryLimit int    // messages kept per room; 0 keeps everything
+}
+
+// Open returns the Store selected by opts.Driver
+func Open(opts Options) (Store, error) {
+	switch opts.Driver {
+	case DriverMemory, "":
+		return NewMemoryStore(opts.RoomHistoryLimit), nil
+	case DriverFile:
+		return OpenFileStore(opts.Path, opts.SnapshotEvery, opts.RoomHistoryLimit, opts.Fsync)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", opts.Driver)
+	}
+}
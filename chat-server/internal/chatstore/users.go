@@ -0,0 +1,101 @@
+package chatstore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User is a registered account. Only hashes of secrets are kept.
+type User struct {
+	Username     string              `json:"username"`
+	PasswordHash []byte              `json:"password_hash"`
+	TokenHashes  map[string]struct{} `json:"token_hashes"` // sha256 of issued session tokens
+	CreatedAt    time.Time           `json:"created_at"`
+}
+
+// Register creates a new account and returns a fresh session token.
+// Password hashing is slow by design, so callers should keep it off the hub goroutine.
+func Register(s Store, username, password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.CreateUser(User{
+		Username:     username,
+		PasswordHash: hash,
+		TokenHashes:  map[string]struct{}{tokenHash: {}},
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LoginPassword checks a password and issues a new session token.
+func LoginPassword(s Store, username, password string) (string, error) {
+	u, err := s.GetUser(username)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return "", ErrInvalidCredentials
+		}
+		return "", err
+	}
+	if bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)) != nil {
+		return "", ErrInvalidCredentials
+	}
+
+	token, tokenHash, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.AddUserToken(username, tokenHash); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LoginToken checks a session token previously issued to username.
+func LoginToken(s Store, username, token string) error {
+	u, err := s.GetUser(username)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+	if _, ok := u.TokenHashes[hashToken(token)]; !ok {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// UserExists reports whether username is registered.
+func UserExists(s Store, username string) bool {
+	_, err := s.GetUser(username)
+	return err == nil
+}
+
+func newToken() (token, tokenHash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,13 +14,91 @@ import (
 type Config struct {
 	ListenAddress   string        `mapstructure:"listen_address"`    // ":9000"
 	Transport       string        `mapstructure:"transport"`         // "tcp" or "websocket"
-	MaxMessageBytes int           `mapstructure:"max_message_bytes"` // 4096
+	MaxMessageBytes int           `mapstructure:"max_message_bytes"` // 4096, longest frame read from a client
+	MaxBodyBytes    int           `mapstructure:"max_body_bytes"`    // 2000, longest message body accepted
 	LogLevel        string        `mapstructure:"log_level"`         // "info", "debug", etc.
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`     // "5s"
-	ReadTimeout     time.Duration `mapstructure:"read_timeout"`      // "30s"
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`      // "30s" of silence before a client is evicted
+	PingInterval    time.Duration `mapstructure:"ping_interval"`     // "10s"; must be shorter than read_timeout
+	ResumeWindow    time.Duration `mapstructure:"resume_window"`     // "1m" a dropped client may reconnect and keep its rooms; 0 disables
+	RoomHistorySize int           `mapstructure:"room_history_size"` // 100 recent messages kept per room for scrollback
+	MaxRooms        int           `mapstructure:"max_rooms"`         // 1000 rooms a join may create up to; 0 = no limit
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`  // "10s" to flush clients' queues on shutdown
+	ShutdownRetry   time.Duration `mapstructure:"shutdown_retry"`    // "5s" reconnect hint sent with server_shutdown; 0 = none
+	MetricsAddress  string        `mapstructure:"metrics_address"`   // "127.0.0.1:9090" serves expvar at /debug/vars; empty = off
+	MOTD            string        `mapstructure:"motd"`              // message of the day, sent after login; empty = none
+	BannedUsers     []string      `mapstructure:"banned_users"`      // usernames refused at login
+	BannedIPs       []string      `mapstructure:"banned_ips"`        // client IPs refused at connect
+	Moderators      []string      `mapstructure:"moderators"`        // usernames allowed to edit and delete anyone's messages
+
+	// WebSocket transport
+	WebSocketAddress string   `mapstructure:"websocket_address"` // ":9001"; also serve WebSocket next to tcp
+	WebSocketPath    string   `mapstructure:"websocket_path"`    // "/ws"
+	WebSocketOrigins []string `mapstructure:"websocket_origins"` // allowed Origin headers; empty = any
+
+	// TLS is enabled for every listener when both files are set.
+	TLSCertFile string `mapstructure:"tls_cert_file"` // "certs/server.crt"
+	TLSKeyFile  string `mapstructure:"tls_key_file"`  // "certs/server.key"
+
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+	SendQueue SendQueue `mapstructure:"send_queue"`
+	Storage   Storage   `mapstructure:"storage"`
+}
+
+// Slow-consumer policies, applied when a client's send queue is full
+const (
+	PolicyDropOldest = "drop_oldest" // discard the oldest queued message and warn the client
+	PolicyDisconnect = "disconnect"  // disconnect the client with a slow_consumer error
+	PolicySpill      = "spill"       // queue into an overflow buffer, then disconnect when it fills
+)
+
+// SendQueue bounds what the server buffers for each client.
+type SendQueue struct {
+	Size         int    `mapstructure:"size"`          // 256 messages
+	Policy       string `mapstructure:"policy"`        // "drop_oldest", "disconnect" or "spill"
+	OverflowSize int    `mapstructure:"overflow_size"` // 1024 extra messages for "spill"
 }
 
-var Cfg Config // populated by Load()
+// Storage selects the chatstore backend.
+type Storage struct {
+	Driver           string `mapstructure:"driver"`             // "memory" or "file"
+	Path             string `mapstructure:"path"`               // "data", directory for the file driver
+	SnapshotEvery    int    `mapstructure:"snapshot_every"`     // 1000 log entries between snapshots
+	Fsync            bool   `mapstructure:"fsync"`              // false; sync the log to disk after every write
+	RoomHistoryLimit int    `mapstructure:"room_history_limit"` // 1000 messages kept per room
+}
+
+// RateLimit holds token-bucket settings. A zero rate disables that limit.
+type RateLimit struct {
+	MessagesPerSecond    float64       `mapstructure:"messages_per_second"`    // 5, per connection
+	MessageBurst         int           `mapstructure:"message_burst"`          // 10
+	UserPerSecond        float64       `mapstructure:"user_per_second"`        // 8, per username across connections
+	UserBurst            int           `mapstructure:"user_burst"`             // 16
+	RoomPerSecond        float64       `mapstructure:"room_per_second"`        // 50, per room
+	RoomBurst            int           `mapstructure:"room_burst"`             // 100
+	ConnectionsPerMinute float64       `mapstructure:"connections_per_minute"` // 30, per IP
+	MaxStrikes           int           `mapstructure:"max_strikes"`            // 20 dropped messages → disconnect + ban
+	StrikeWindow         time.Duration `mapstructure:"strike_window"`          // "1m"
+	BanDuration          time.Duration `mapstructure:"ban_duration"`           // "5m"
+}
+
+// current is the config in use. Load and Reload swap in a new one, so a
+// *Config from Get is a snapshot that must not be modified.
+var current atomic.Pointer[Config]
+
+func init() {
+	current.Store(&Config{})
+}
+
+// Get returns the config in use.
+func Get() *Config {
+	return current.Load()
+}
+
+// Set replaces the config in use without validating it (for tests).
+func Set(c *Config) {
+	current.Store(c)
+}
 
 // Load reads config from file + env vars.
 // Call this once at startup (e.g., in cmd/server/main.go).
@@ -38,19 +119,172 @@ func Load(configName string) error {
 	viper.SetDefault("listen_address", ":9000")
 	viper.SetDefault("transport", "tcp")
 	viper.SetDefault("max_message_bytes", 4096)
+	viper.SetDefault("max_body_bytes", 2000)
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("write_timeout", "5s")
 	viper.SetDefault("read_timeout", "30s")
+	viper.SetDefault("ping_interval", "10s")
+	viper.SetDefault("resume_window", "1m")
+	viper.SetDefault("room_history_size", 100)
+	viper.SetDefault("max_rooms", 1000)
+	viper.SetDefault("websocket_address", "")
+	viper.SetDefault("websocket_path", "/ws")
+	viper.SetDefault("tls_cert_file", "")
+	viper.SetDefault("tls_key_file", "")
+	viper.SetDefault("rate_limit.messages_per_second", 5)
+	viper.SetDefault("rate_limit.message_burst", 10)
+	viper.SetDefault("rate_limit.user_per_second", 8)
+	viper.SetDefault("rate_limit.user_burst", 16)
+	viper.SetDefault("rate_limit.room_per_second", 50)
+	viper.SetDefault("rate_limit.room_burst", 100)
+	viper.SetDefault("rate_limit.connections_per_minute", 30)
+	viper.SetDefault("rate_limit.max_strikes", 20)
+	viper.SetDefault("rate_limit.strike_window", "1m")
+	viper.SetDefault("rate_limit.ban_duration", "5m")
+	viper.SetDefault("shutdown_timeout", "10s")
+	viper.SetDefault("shutdown_retry", "5s")
+	viper.SetDefault("metrics_address", "")
+	viper.SetDefault("motd", "")
+	viper.SetDefault("banned_users", []string{})
+	viper.SetDefault("moderators", []string{})
+	viper.SetDefault("banned_ips", []string{})
+	viper.SetDefault("send_queue.size", 256)
+	viper.SetDefault("send_queue.policy", PolicyDropOldest)
+	viper.SetDefault("send_queue.overflow_size", 1024)
+	viper.SetDefault("storage.driver", "memory")
+	viper.SetDefault("storage.path", "data")
+	viper.SetDefault("storage.snapshot_every", 1000)
+	viper.SetDefault("storage.fsync", false)
+	viper.SetDefault("storage.room_history_limit", 1000)
+
+	cfg, err := read()
+	if err != nil {
+		return err
+	}
+	current.Store(cfg)
+	return nil
+}
 
+// read loads the file and env vars into a new, validated Config.
+func read() (*Config, error) {
 	// It’s okay if the file doesn’t exist; use defaults + env.
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-			return fmt.Errorf("read config: %w", err)
+			return nil, fmt.Errorf("read config: %w", err)
 		}
 	}
 
-	if err := viper.Unmarshal(&Cfg); err != nil {
-		return fmt.Errorf("unmarshal config: %w", err)
+	var cfg Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal config: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if c.PingInterval <= 0 || c.PingInterval >= c.ReadTimeout {
+		return fmt.Errorf("ping_interval (%s) must be positive and shorter than read_timeout (%s)", c.PingInterval, c.ReadTimeout)
+	}
+	switch c.SendQueue.Policy {
+	case PolicyDropOldest, PolicyDisconnect, PolicySpill:
+	default:
+		return fmt.Errorf("send_queue.policy %q must be %s, %s or %s", c.SendQueue.Policy, PolicyDropOldest, PolicyDisconnect, PolicySpill)
+	}
+	if c.SendQueue.Size <= 0 {
+		return fmt.Errorf("send_queue.size must be positive")
+	}
+	if c.MaxMessageBytes <= 0 {
+		return fmt.Errorf("max_message_bytes must be positive")
+	}
+	if c.MaxBodyBytes <= 0 || c.MaxBodyBytes >= c.MaxMessageBytes {
+		return fmt.Errorf("max_body_bytes (%d) must be positive and below max_message_bytes (%d)", c.MaxBodyBytes, c.MaxMessageBytes)
 	}
 	return nil
 }
+
+// Change is one setting that differs after Reload.
+type Change struct {
+	Key      string // mapstructure key, e.g. "rate_limit.user_burst"
+	Old, New string
+	Restart  bool // only read at startup; the running value stays in use
+}
+
+// startupKeys are read once at startup: listeners and storage. A reload
+// reports changes to them but keeps the running values.
+var startupKeys = []string{
+	"listen_address", "transport", "metrics_address",
+	"websocket_address", "websocket_path", "websocket_origins",
+	"tls_cert_file", "tls_key_file", "storage.",
+}
+
+func needsRestart(key string) bool {
+	for _, k := range startupKeys {
+		if key == k || (strings.HasSuffix(k, ".") && strings.HasPrefix(key, k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload re-reads the config file and env vars, e.g. on SIGHUP. An invalid
+// config is rejected and the running one kept. Otherwise it becomes the
+// config in use, except for settings that need a restart. Everything else
+// takes effect the next time it is read: timeouts on the next read or
+// write, limits and the send queue for new connections.
+func Reload() ([]Change, error) {
+	next, err := read()
+	if err != nil {
+		return nil, err
+	}
+	running := Get()
+	changes := diff("", reflect.ValueOf(*running), reflect.ValueOf(*next))
+
+	// keep what only applies at startup
+	next.ListenAddress = running.ListenAddress
+	next.Transport = running.Transport
+	next.MetricsAddress = running.MetricsAddress
+	next.WebSocketAddress = running.WebSocketAddress
+	next.WebSocketPath = running.WebSocketPath
+	next.WebSocketOrigins = running.WebSocketOrigins
+	next.TLSCertFile = running.TLSCertFile
+	next.TLSKeyFile = running.TLSKeyFile
+	next.Storage = running.Storage
+	current.Store(next)
+	return changes, nil
+}
+
+// diff lists the fields of two Config (or nested struct) values that
+// differ, keyed by their mapstructure tags.
+func diff(prefix string, old, next reflect.Value) []Change {
+	var changes []Change
+	for i := 0; i < old.NumField(); i++ {
+		field := old.Type().Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+		if field.Type.Kind() == reflect.Struct {
+			changes = append(changes, diff(key+".", old.Field(i), next.Field(i))...)
+			continue
+		}
+		o, n := old.Field(i).Interface(), next.Field(i).Interface()
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+		changes = append(changes, Change{
+			Key:     key,
+			Old:     fmt.Sprint(o),
+			New:     fmt.Sprint(n),
+			Restart: needsRestart(key),
+		})
+	}
+	return changes
+}
+
+// TLSEnabled reports whether listeners should serve TLS.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
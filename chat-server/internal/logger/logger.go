@@ -6,29 +6,34 @@ import (
 	"strings"
 )
 
+// level is shared by every logger from New, so SetLevel changes them all.
+var level slog.LevelVar
+
 // New builds a slog.Logger using the desired level.
 // Usage:
 //
 //	log := logger.New("debug")
 //	log.Info("server started")
-func New(level string) *slog.Logger {
-	var lvl slog.Level
+func New(lvl string) *slog.Logger {
+	SetLevel(lvl)
+	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: &level,
+	})
+	return slog.New(handler)
+}
 
-	switch strings.ToLower(level) {
+// SetLevel changes the level of loggers from New, e.g. on config reload.
+func SetLevel(lvl string) {
+	switch strings.ToLower(lvl) {
 	case "debug":
-		lvl = slog.LevelDebug
+		level.Set(slog.LevelDebug)
 	case "warn", "warning":
-		lvl = slog.LevelWarn
+		level.Set(slog.LevelWarn)
 	case "error":
-		lvl = slog.LevelError
+		level.Set(slog.LevelError)
 	case "info", "":
-		lvl = slog.LevelInfo
+		level.Set(slog.LevelInfo)
 	default:
-		lvl = slog.LevelInfo
+		level.Set(slog.LevelInfo)
 	}
-
-	handler := slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: lvl,
-	})
-	return slog.New(handler)
 }
@@ -0,0 +1,37 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+
+	"github.com/danieljhkim/chat-server/internal/app"
+	"github.com/danieljhkim/chat-server/internal/config"
+)
+
+// StartMetrics serves expvar at /debug/vars, including the send queue of
+// every connected client under "client_queues". Call it at most once.
+func StartMetrics(ctx context.Context, addr string, hub *app.Hub, log *slog.Logger) error {
+	expvar.Publish("client_queues", expvar.Func(func() any { return hub.QueueStats() }))
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: config.Get().ReadTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	log.Info("metrics listening", "addr", addr, "path", "/debug/vars")
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -2,18 +2,31 @@ package netutil
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"log/slog"
 	"net"
+	"time"
 
 	"github.com/danieljhkim/chat-server/internal/app"
+	"github.com/danieljhkim/chat-server/internal/config"
+	"github.com/danieljhkim/chat-server/internal/protocol"
 )
 
 func StartTCP(ctx context.Context, addr string, hub *app.Hub, log *slog.Logger) error {
+	tlsCfg, err := loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
-	log.Info("tcp listening", "addr", addr)
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+	log.Info("tcp listening", "addr", addr, "tls", tlsCfg != nil)
 
 	go func() {
 		<-ctx.Done()
@@ -32,10 +45,27 @@ func StartTCP(ctx context.Context, addr string, hub *app.Hub, log *slog.Logger)
 			}
 		}
 
-		client := app.NewClient(conn, hub, log)
-		hub.Register <- client
-
-		go client.ReadLoop()
-		go client.WriteLoop()
+		if !hub.Guard.AllowConnect(app.RemoteIP(conn.RemoteAddr())) {
+			log.Warn("connection refused: rate limited or banned", "remote", conn.RemoteAddr())
+			rejectConn(conn, protocol.ErrCodeRateLimited, "too many connection attempts; try again later")
+			continue
+		}
+		serveClient(ctx, conn, hub, log)
 	}
 }
+
+// rejectConn tells a refused client why before closing it.
+func rejectConn(conn net.Conn, code, reason string) {
+	_ = conn.SetWriteDeadline(time.Now().Add(config.Get().WriteTimeout))
+	_ = json.NewEncoder(conn).Encode(protocol.NewErrorMessage(code, reason))
+	_ = conn.Close()
+}
+
+// serveClient starts conn's read/write loops without waiting on the hub;
+// ReadLoop registers the client itself. The client outlives ctx: on
+// shutdown the hub notifies and drains it before closing it.
+func serveClient(ctx context.Context, conn net.Conn, hub *app.Hub, log *slog.Logger) {
+	client := app.NewClient(context.WithoutCancel(ctx), conn, hub, log)
+	go client.ReadLoop()
+	go client.WriteLoop()
+}
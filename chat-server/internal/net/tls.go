@@ -0,0 +1,23 @@
+package netutil
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/danieljhkim/chat-server/internal/config"
+)
+
+// loadTLSConfig returns nil when TLS is not configured.
+func loadTLSConfig() (*tls.Config, error) {
+	if !config.Get().TLSEnabled() {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(config.Get().TLSCertFile, config.Get().TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load tls key pair: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,195 @@
+package netutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/danieljhkim/chat-server/internal/app"
+	"github.com/danieljhkim/chat-server/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+// StartWebSocket serves the chat protocol over WebSocket (wss when TLS is
+// configured) on config.Get().WebSocketPath.
+// Each text frame carries one JSON WireMessage, so browser clients share rooms
+// with chat-cli users connected over TCP.
+func StartWebSocket(ctx context.Context, addr string, hub *app.Hub, log *slog.Logger) error {
+	tlsCfg, err := loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  config.Get().MaxMessageBytes,
+		WriteBufferSize: config.Get().MaxMessageBytes,
+		CheckOrigin:     checkOrigin(config.Get().WebSocketOrigins),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(config.Get().WebSocketPath, func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !hub.Guard.AllowConnect(ip) {
+			log.Warn("connection refused: rate limited or banned", "remote", r.RemoteAddr)
+			http.Error(w, "too many connection attempts; try again later", http.StatusTooManyRequests)
+			return
+		}
+
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Warn("websocket upgrade failed", "remote", r.RemoteAddr, "err", err)
+			return
+		}
+		// the client's frameReader skips oversized messages with an error
+		// reply; gorilla's limit only catches frames it would give up on
+		ws.SetReadLimit(int64(config.Get().MaxMessageBytes * app.MaxSkipFactor))
+		serveClient(ctx, newWSConn(ws), hub, log)
+	})
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: config.Get().ReadTimeout,
+		TLSConfig:         tlsCfg,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+
+	log.Info("websocket listening", "addr", addr, "path", config.Get().WebSocketPath, "tls", tlsCfg != nil)
+	if tlsCfg != nil {
+		// certificates are already loaded into srv.TLSConfig
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// checkOrigin allows any origin when the allow-list is empty.
+func checkOrigin(allowed []string) func(r *http.Request) bool {
+	if len(allowed) == 0 {
+		return func(*http.Request) bool { return true }
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		for _, o := range allowed {
+			if o == origin {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// wsConn adapts a WebSocket connection to net.Conn so app.Client can read
+// newline-delimited frames and write JSON exactly as it does over TCP.
+type wsConn struct {
+	ws *websocket.Conn
+
+	reader  io.Reader // current frame, nil between frames
+	last    byte      // last byte handed to the caller
+	needsNL bool      // frame ended without '\n' and p had no room for it
+
+	wmu sync.Mutex // gorilla allows one concurrent writer
+}
+
+func newWSConn(ws *websocket.Conn) *wsConn {
+	return &wsConn{ws: ws, last: '\n'}
+}
+
+// Read returns frame payloads back to back, inserting a '\n' after any frame
+// that doesn't already end with one.
+func (c *wsConn) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if c.needsNL {
+		c.needsNL = false
+		c.last = '\n'
+		p[0] = '\n'
+		return 1, nil
+	}
+
+	for {
+		if c.reader == nil {
+			_, r, err := c.ws.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = r
+		}
+
+		n, err := c.reader.Read(p)
+		if n > 0 {
+			c.last = p[n-1]
+		}
+		if err == io.EOF {
+			c.reader = nil
+			if c.last != '\n' {
+				if n < len(p) {
+					p[n] = '\n'
+					n++
+					c.last = '\n'
+				} else {
+					c.needsNL = true
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+// Write sends p as a single text frame without its trailing newline.
+func (c *wsConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	payload := p
+	if n := len(payload); n > 0 && payload[n-1] == '\n' {
+		payload = payload[:n-1]
+	}
+	if err := c.ws.WriteMessage(websocket.TextMessage, payload); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *wsConn) Close() error {
+	c.wmu.Lock()
+	_ = c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	c.wmu.Unlock()
+	return c.ws.Close()
+}
+
+func (c *wsConn) LocalAddr() net.Addr  { return c.ws.LocalAddr() }
+func (c *wsConn) RemoteAddr() net.Addr { return c.ws.RemoteAddr() }
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+func (c *wsConn) SetReadDeadline(t time.Time) error  { return c.ws.SetReadDeadline(t) }
+func (c *wsConn) SetWriteDeadline(t time.Time) error { return c.ws.SetWriteDeadline(t) }
@@ -7,19 +7,96 @@ import (
 	"github.com/danieljhkim/chat-server/internal/chatstore"
 )
 
+// Protocol versions. Bump ProtocolVersion on incompatible wire changes;
+// servers accept clients speaking MinProtocolVersion..ProtocolVersion.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// Capabilities negotiated in hello/welcome
+const (
+	CapHistory = "history" // room scrollback via get_history / join limit
+	CapAcks    = "acks"    // dm_ack replies to send_dm
+)
+
+// Limit keys advertised in welcome
+const (
+	LimitMaxMessageBytes = "max_message_bytes" // longest frame the server reads
+	LimitMaxBodyBytes    = "max_body_bytes"    // longest message body the server accepts
+	LimitRoomHistorySize = "room_history_size"
+	LimitPingInterval    = "ping_interval_seconds" // server pings at least this often
+	LimitReadTimeout     = "read_timeout_seconds"  // server drops clients silent this long
+)
+
+// Error codes carried in error responses. Codes are stable and meant for
+// programs; Message holds the human-readable text.
+const (
+	ErrCodeInvalidPayload     = "invalid_payload"     // malformed JSON or missing/invalid fields
+	ErrCodeUnknownType        = "unknown_type"        // message type the server does not handle
+	ErrCodeInvalidState       = "invalid_state"       // request not valid now, e.g. before hello or a second login
+	ErrCodeUnsupportedVersion = "unsupported_version" // hello protocol_version outside the supported range
+	ErrCodeNotAuthorized      = "not_authorized"      // not logged in, or not allowed to act on the target
+	ErrCodeAuthFailed         = "auth_failed"         // wrong username, password or token
+	ErrCodeUserExists         = "user_exists"         // register with a taken username
+	ErrCodeUserNotFound       = "user_not_found"
+	ErrCodeRoomNotFound       = "room_not_found"
+	ErrCodeRoomLimit          = "room_limit"        // join would create a room past the server's max_rooms
+	ErrCodeMessageNotFound    = "message_not_found" // edit/delete of an unknown or deleted msg_id
+	ErrCodeRateLimited        = "rate_limited"      // message dropped by a rate limit
+	ErrCodeBanned             = "banned"            // temporarily banned for flooding
+	ErrCodeSlowConsumer       = "slow_consumer"     // disconnected for falling too far behind
+	ErrCodeMessageTooLarge    = "message_too_large" // frame or body over the advertised limit
+	ErrCodeInternal           = "internal_error"
+)
+
 // Message types for different chat operations
 const (
+	// Handshake
+	TypeHello   = "hello"   // request: first frame on every connection
+	TypeWelcome = "welcome" // response: server version, capabilities and limits
+
+	// Authentication
+	TypeRegister = "register" // request: username + password
+	TypeLogin    = "login"    // request: username + password or token
+	TypeAuthOK   = "auth_ok"  // response: username + session token
+
 	// Room management
 	TypeJoin    = "join"
 	TypeLeave   = "leave"
 	TypeRoomMsg = "room_msg"
 	TypeAction  = "action" // /me style messages
 
+	// Room history
+	TypeGetHistory = "get_history" // request: room + limit
+	TypeHistory    = "history"     // response: recent messages, oldest first
+
+	// Threads: a room_msg/action with parent_id is a reply
+	TypeGetThread = "get_thread" // request: room + msg_id of any message in the thread
+	TypeThread    = "thread"     // response: thread_id + the root and its replies, oldest first
+
+	// Editing and retracting sent messages by msg_id; room is set for
+	// room messages and empty for DMs
+	TypeEdit           = "edit"            // request: msg_id + new body
+	TypeDelete         = "delete"          // request: msg_id
+	TypeMessageEdited  = "message_edited"  // notification: msg_id, author and new body
+	TypeMessageDeleted = "message_deleted" // notification: msg_id and author
+
+	// Reactions on room messages, keyed by msg_id and emoji
+	TypeReact     = "react"     // request: room + msg_id + emoji or :shortcode:
+	TypeUnreact   = "unreact"   // request: room + msg_id + emoji or :shortcode:
+	TypeReactions = "reactions" // notification: msg_id and its reaction counts
+
+	// @mentions of users who were not in the room
+	TypeListMentions = "list_mentions" // request
+	TypeMentionList  = "mention_list"  // response: the mentioning messages, oldest first; clears them
+
 	// Direct messaging
 	TypeDM     = "dm"
 	TypeListDM = "list_dm" // request
 	TypeDMList = "dm_list" // response
 	TypeSendDM = "send_dm"
+	TypeDMAck  = "dm_ack" // response: DM delivered or queued
 
 	// Room listing
 	TypeListRooms = "list_rooms" // request
@@ -43,8 +120,9 @@ const (
 	TypeWarning = "warning" // warning message
 
 	// Server management
-	TypeServerInfo = "server_info" // server information
-	TypeStats      = "stats"       // server statistics
+	TypeServerInfo     = "server_info"     // server information
+	TypeStats          = "stats"           // server statistics
+	TypeServerShutdown = "server_shutdown" // server is stopping; reconnect after retry_after
 )
 
 // WireMessage represents every payload on the wire
@@ -53,19 +131,64 @@ type WireMessage struct {
 	Type      string    `json:"type"`                // required - message type
 	Timestamp time.Time `json:"timestamp,omitempty"` // message timestamp
 
+	// Correlation: a client sets ID on a request and the server copies it
+	// into ReplyTo on every direct response (including errors and warnings)
+	ID      string `json:"id,omitempty"`       // client-chosen request ID
+	ReplyTo string `json:"reply_to,omitempty"` // ID of the request this message answers
+
+	// Server-assigned identity of a room_msg, action or dm; Seq and
+	// Timestamp are likewise set by the server, never taken from clients
+	MsgID   string `json:"msg_id,omitempty"`  // unique message ID
+	Edited  bool   `json:"edited,omitempty"`  // body was changed after sending
+	Deleted bool   `json:"deleted,omitempty"` // retracted by its author or a moderator; body is empty
+
+	// Threaded replies: the client sets ParentID, the server fills in
+	// ThreadID and a Quote of the parent
+	ParentID string `json:"parent_id,omitempty"` // msg_id of the message this replies to
+	ThreadID string `json:"thread_id,omitempty"` // msg_id of the thread's first message
+	Quote    *Quote `json:"quote,omitempty"`     // the parent as it is now
+
+	// Reactions: the client sets Emoji on react/unreact; room messages
+	// carry their Reactions in history and reactions notifications
+	Emoji     string     `json:"emoji,omitempty"`     // emoji or :shortcode: to add or remove
+	Reactions []Reaction `json:"reactions,omitempty"` // counts per emoji, in order of first use
+
+	// Mentions lists the registered users a room_msg/action @mentions, as
+	// found by the server
+	Mentions []string `json:"mentions,omitempty"`
+
 	// Room and user identification
 	Room     string `json:"room,omitempty"`     // room name for join/room_msg
 	Username string `json:"username,omitempty"` // sender username
 	Target   string `json:"target,omitempty"`   // target user for DM
 
+	// Handshake (hello/welcome)
+	ProtocolVersion int            `json:"protocol_version,omitempty"` // wire protocol version
+	Agent           string         `json:"agent,omitempty"`            // software name/version, e.g. "chat-cli/1.0.0"
+	Capabilities    []string       `json:"capabilities,omitempty"`     // supported (hello) or negotiated (welcome) features
+	Limits          map[string]int `json:"limits,omitempty"`           // server limits, see Limit* keys
+
+	// Credentials (register/login requests and auth_ok response)
+	Password string `json:"password,omitempty"` // plaintext password, only sent over the wire at login
+	Token    string `json:"token,omitempty"`    // session token issued by the server
+
+	// Resuming after a dropped connection
+	ResumeToken string `json:"resume_token,omitempty"` // issued in auth_ok, presented at the next login
+	Seq         uint64 `json:"seq,omitempty"`          // per-room sequence number of a room_msg/action, no gaps
+	Since       uint64 `json:"since,omitempty"`        // join/get_history: return messages after this seq
+	RetryAfter  int    `json:"retry_after,omitempty"`  // server_shutdown: seconds to wait before reconnecting
+
 	// Message content
 	Body    string `json:"body,omitempty"`    // message text content
 	Message string `json:"message,omitempty"` // system/error message
+	Code    string `json:"code,omitempty"`    // error code, see ErrCode*
+	Limit   int    `json:"limit,omitempty"`   // how many history messages to return (join, get_history)
 
 	// List responses
-	Rooms []string       `json:"rooms,omitempty"` // room list response
-	Users []string       `json:"users,omitempty"` // user list response
-	DMs   []chatstore.DM `json:"DM,omitempty"`    // user list response
+	Rooms   []string       `json:"rooms,omitempty"`   // room list response
+	History []WireMessage  `json:"history,omitempty"` // history response
+	Users   []string       `json:"users,omitempty"`   // user list response
+	DMs     []chatstore.DM `json:"DM,omitempty"`      // user list response
 
 	// Statistics and metadata
 	UserCount    int               `json:"user_count,omitempty"`    // number of users in room
@@ -75,6 +198,21 @@ type WireMessage struct {
 	Metadata     map[string]string `json:"metadata,omitempty"`      // additional data
 }
 
+// Quote is a snippet of the message a reply answers
+type Quote struct {
+	Username string `json:"username"`
+	Seq      uint64 `json:"seq,omitempty"`
+	Body     string `json:"body,omitempty"` // opening words; empty once deleted
+	Deleted  bool   `json:"deleted,omitempty"`
+}
+
+// Reaction is one emoji on a message and who reacted with it
+type Reaction struct {
+	Emoji string   `json:"emoji"`
+	Count int      `json:"count"`
+	Users []string `json:"users,omitempty"` // in the order they reacted
+}
+
 // NewMessage creates a new WireMessage with timestamp
 func NewMessage(msgType string) *WireMessage {
 	return &WireMessage{
@@ -111,6 +249,68 @@ func NewDirectMessage(sender, target, body string) *WireMessage {
 	return msg
 }
 
+// NewHelloMessage opens the handshake with the client's version and capabilities
+func NewHelloMessage(agent string, capabilities []string) *WireMessage {
+	msg := NewMessage(TypeHello)
+	msg.ProtocolVersion = ProtocolVersion
+	msg.Agent = agent
+	msg.Capabilities = capabilities
+	return msg
+}
+
+// NewWelcomeMessage completes the handshake with negotiated capabilities and limits
+func NewWelcomeMessage(agent string, capabilities []string, limits map[string]int) *WireMessage {
+	msg := NewMessage(TypeWelcome)
+	msg.ProtocolVersion = ProtocolVersion
+	msg.Agent = agent
+	msg.Capabilities = capabilities
+	msg.Limits = limits
+	return msg
+}
+
+// HasCapability reports whether name is listed in the message's capabilities
+func (m *WireMessage) HasCapability(name string) bool {
+	for _, c := range m.Capabilities {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRegisterMessage creates an account registration request
+func NewRegisterMessage(username, password string) *WireMessage {
+	msg := NewMessage(TypeRegister)
+	msg.Username = username
+	msg.Password = password
+	return msg
+}
+
+// NewLoginMessage creates a login request; set either password or token
+func NewLoginMessage(username, password, token string) *WireMessage {
+	msg := NewMessage(TypeLogin)
+	msg.Username = username
+	msg.Password = password
+	msg.Token = token
+	return msg
+}
+
+// NewAuthOKMessage creates a successful authentication response
+func NewAuthOKMessage(username, token string) *WireMessage {
+	msg := NewMessage(TypeAuthOK)
+	msg.Username = username
+	msg.Token = token
+	return msg
+}
+
+// NewDMAckMessage acknowledges a send_dm request
+func NewDMAckMessage(target, status string) *WireMessage {
+	msg := NewMessage(TypeDMAck)
+	msg.Target = target
+	msg.Message = status
+	return msg
+}
+
 // NewJoinMessage creates a join room message
 func NewJoinMessage(room, username string) *WireMessage {
 	msg := NewMessage(TypeJoin)
@@ -145,6 +345,37 @@ func NewUserLeftNotification(room, username string, userCount int) *WireMessage
 	return msg
 }
 
+// NewMessageEditedNotification tells clients a message's body changed
+func NewMessageEditedNotification(room, msgID, author, body string) *WireMessage {
+	msg := NewMessage(TypeMessageEdited)
+	msg.Room = room
+	msg.MsgID = msgID
+	msg.Username = author
+	msg.Body = body
+	msg.Edited = true
+	return msg
+}
+
+// NewMessageDeletedNotification tells clients a message was retracted
+func NewMessageDeletedNotification(room, msgID, author string) *WireMessage {
+	msg := NewMessage(TypeMessageDeleted)
+	msg.Room = room
+	msg.MsgID = msgID
+	msg.Username = author
+	msg.Deleted = true
+	return msg
+}
+
+// NewReactionsNotification tells clients a message's reactions changed
+func NewReactionsNotification(room, msgID string, seq uint64, reactions []Reaction) *WireMessage {
+	msg := NewMessage(TypeReactions)
+	msg.Room = room
+	msg.MsgID = msgID
+	msg.Seq = seq
+	msg.Reactions = reactions
+	return msg
+}
+
 // NewUserListResponse creates a user list response
 func NewUserListResponse(room string, users []string) *WireMessage {
 	msg := NewMessage(TypeUserList)
@@ -162,9 +393,55 @@ func NewRoomsListResponse(rooms []string) *WireMessage {
 	return msg
 }
 
-// NewErrorMessage creates an error message
-func NewErrorMessage(errorMsg string) *WireMessage {
+// NewHistoryRequest asks for up to limit recent messages in room
+func NewHistoryRequest(room string, limit int) *WireMessage {
+	msg := NewMessage(TypeGetHistory)
+	msg.Room = room
+	msg.Limit = limit
+	return msg
+}
+
+// NewHistoryResponse creates a room history response. since is the
+// request's Since, so clients can tell catch-up from plain scrollback.
+func NewHistoryResponse(room string, since uint64, history []WireMessage) *WireMessage {
+	msg := NewMessage(TypeHistory)
+	msg.Room = room
+	msg.Since = since
+	msg.History = history
+	msg.MessageCount = len(history)
+	return msg
+}
+
+// NewThreadRequest asks for the whole thread msgID belongs to
+func NewThreadRequest(room, msgID string) *WireMessage {
+	msg := NewMessage(TypeGetThread)
+	msg.Room = room
+	msg.MsgID = msgID
+	return msg
+}
+
+// NewThreadResponse returns a thread's root and replies, oldest first
+func NewThreadResponse(room, threadID string, messages []WireMessage) *WireMessage {
+	msg := NewMessage(TypeThread)
+	msg.Room = room
+	msg.ThreadID = threadID
+	msg.History = messages
+	msg.MessageCount = len(messages)
+	return msg
+}
+
+// NewMentionListResponse returns the messages a user was mentioned in
+func NewMentionListResponse(messages []WireMessage) *WireMessage {
+	msg := NewMessage(TypeMentionList)
+	msg.History = messages
+	msg.MessageCount = len(messages)
+	return msg
+}
+
+// NewErrorMessage creates an error message with one of the ErrCode* codes
+func NewErrorMessage(code, errorMsg string) *WireMessage {
 	msg := NewMessage(TypeError)
+	msg.Code = code
 	msg.Message = errorMsg
 	return msg
 }
@@ -183,6 +460,15 @@ func NewWarningMessage(warning string) *WireMessage {
 	return msg
 }
 
+// NewServerShutdownMessage tells clients the server is stopping. retryAfter
+// hints how many seconds to wait before reconnecting; 0 gives no hint.
+func NewServerShutdownMessage(reason string, retryAfter int) *WireMessage {
+	msg := NewMessage(TypeServerShutdown)
+	msg.Message = reason
+	msg.RetryAfter = retryAfter
+	return msg
+}
+
 // NewServerStatsMessage creates a server statistics message
 func NewServerStatsMessage(userCount, roomCount, messageCount int, uptime string) *WireMessage {
 	msg := NewMessage(TypeStats)
@@ -207,7 +493,7 @@ func NewPongMessage() *WireMessage {
 func (m *WireMessage) IsSystemMessage() bool {
 	systemTypes := []string{
 		TypeUserJoined, TypeUserLeft, TypeError, TypeInfo,
-		TypeWarning, TypePing, TypePong, TypeStatus,
+		TypeWarning, TypePing, TypePong, TypeStatus, TypeServerShutdown,
 	}
 
 	for _, sysType := range systemTypes {
@@ -233,8 +519,8 @@ func (m *WireMessage) IsUserMessage() bool {
 // IsRequestMessage returns true if the message is a request
 func (m *WireMessage) IsRequestMessage() bool {
 	requestTypes := []string{
-		TypeJoin, TypeLeave, TypeListRooms, TypeListUsers,
-		TypeRoomsName, TypePing,
+		TypeHello, TypeRegister, TypeLogin, TypeJoin, TypeLeave, TypeListRooms, TypeListUsers,
+		TypeRoomsName, TypePing, TypeGetHistory,
 	}
 
 	for _, reqType := range requestTypes {
@@ -249,7 +535,7 @@ func (m *WireMessage) IsRequestMessage() bool {
 func (m *WireMessage) IsResponseMessage() bool {
 	responseTypes := []string{
 		TypeRoomsList, TypeUserList, TypePong, TypeError,
-		TypeInfo, TypeStats,
+		TypeInfo, TypeStats, TypeWelcome, TypeAuthOK, TypeDMAck, TypeHistory,
 	}
 
 	for _, respType := range responseTypes {
@@ -284,6 +570,18 @@ func (m *WireMessage) Validate() error {
 	}
 
 	switch m.Type {
+	case TypeHello:
+		if m.ProtocolVersion == 0 {
+			return fmt.Errorf("protocol_version is required for hello")
+		}
+	case TypeRegister:
+		if m.Username == "" || m.Password == "" {
+			return fmt.Errorf("username and password are required for register")
+		}
+	case TypeLogin:
+		if m.Username == "" || (m.Password == "" && m.Token == "") {
+			return fmt.Errorf("username and a password or token are required for login")
+		}
 	case TypeJoin, TypeLeave:
 		if m.Room == "" || m.Username == "" {
 			return fmt.Errorf("room and username are required for %s", m.Type)
@@ -292,13 +590,29 @@ func (m *WireMessage) Validate() error {
 		if m.Room == "" || m.Username == "" || m.Body == "" {
 			return fmt.Errorf("room, username, and body are required for %s", m.Type)
 		}
-	case TypeDM:
+	case TypeDM, TypeSendDM:
 		if m.Username == "" || m.Target == "" || m.Body == "" {
 			return fmt.Errorf("username, target, and body are required for DM")
 		}
-	case TypeListUsers:
+	case TypeEdit:
+		if m.MsgID == "" || m.Body == "" {
+			return fmt.Errorf("msg_id and body are required for edit")
+		}
+	case TypeDelete:
+		if m.MsgID == "" {
+			return fmt.Errorf("msg_id is required for delete")
+		}
+	case TypeReact, TypeUnreact:
+		if m.Room == "" || m.MsgID == "" || m.Emoji == "" {
+			return fmt.Errorf("room, msg_id and emoji are required for %s", m.Type)
+		}
+	case TypeGetThread:
+		if m.Room == "" || m.MsgID == "" {
+			return fmt.Errorf("room and msg_id are required for get_thread")
+		}
+	case TypeListUsers, TypeGetHistory:
 		if m.Room == "" {
-			return fmt.Errorf("room is required for list users request")
+			return fmt.Errorf("room is required for %s request", m.Type)
 		}
 	}
 
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket that refills at rate tokens per second up to burst.
+// A Bucket is not safe for concurrent use; see Keyed for a shared limiter.
+type Bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket. A rate <= 0 disables limiting.
+func NewBucket(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes one token if available.
+func (b *Bucket) Allow() bool {
+	return b.allowAt(time.Now())
+}
+
+func (b *Bucket) allowAt(now time.Time) bool {
+	if b.rate <= 0 {
+		return true
+	}
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Keyed holds one Bucket per key (IP, username, room) and forgets idle keys.
+type Keyed struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+// idleAfter is how long a key may go unused before its bucket is dropped.
+// A dropped bucket would have refilled to burst by then anyway.
+const idleAfter = 10 * time.Minute
+
+func NewKeyed(rate float64, burst int) *Keyed {
+	return &Keyed{
+		rate:      rate,
+		burst:     burst,
+		buckets:   make(map[string]*Bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// SetRate changes the limit. Buckets start over, full, at the new rate.
+func (k *Keyed) SetRate(rate float64, burst int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.rate, k.burst = rate, burst
+	k.buckets = make(map[string]*Bucket)
+}
+
+// Allow takes one token from key's bucket.
+func (k *Keyed) Allow(key string) bool {
+	now := time.Now()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.rate <= 0 {
+		return true
+	}
+
+	if now.Sub(k.lastSweep) > idleAfter {
+		for key, b := range k.buckets {
+			if now.Sub(b.last) > idleAfter {
+				delete(k.buckets, key)
+			}
+		}
+		k.lastSweep = now
+	}
+
+	b, ok := k.buckets[key]
+	if !ok {
+		b = NewBucket(k.rate, k.burst)
+		k.buckets[key] = b
+	}
+	return b.allowAt(now)
+}
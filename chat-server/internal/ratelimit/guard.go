@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits configures a Guard. Rates are per second unless noted; zero disables a limit.
+type Limits struct {
+	ConnRate          float64       // messages per connection
+	ConnBurst         int           //
+	UserRate          float64       // messages per username, across connections
+	UserBurst         int           //
+	RoomRate          float64       // messages into a single room
+	RoomBurst         int           //
+	ConnectsPerMinute float64       // connection attempts per IP
+	MaxStrikes        int           // violations within StrikeWindow before a ban
+	StrikeWindow      time.Duration //
+	BanDuration       time.Duration //
+}
+
+// Guard tracks shared rate limits, strikes and temporary bans.
+// It is safe for concurrent use by listeners, clients and the hub.
+type Guard struct {
+	users    *Keyed
+	rooms    *Keyed
+	connects *Keyed
+
+	mu      sync.Mutex
+	limits  Limits
+	strikes map[string]*Strikes
+	bans    map[string]time.Time // key → ban expiry
+	blocked map[string]struct{}  // keys banned until unblocked, see SetBlocked
+}
+
+func NewGuard(l Limits) *Guard {
+	return &Guard{
+		users:    NewKeyed(l.UserRate, l.UserBurst),
+		rooms:    NewKeyed(l.RoomRate, l.RoomBurst),
+		connects: NewKeyed(l.ConnectsPerMinute/60, max(1, int(l.ConnectsPerMinute))),
+		limits:   l,
+		strikes:  make(map[string]*Strikes),
+		bans:     make(map[string]time.Time),
+		blocked:  make(map[string]struct{}),
+	}
+}
+
+// SetLimits replaces the limits. Shared buckets start over at the new
+// rates; connections keep the bucket they were given.
+func (g *Guard) SetLimits(l Limits) {
+	g.mu.Lock()
+	g.limits = l
+	g.mu.Unlock()
+	g.users.SetRate(l.UserRate, l.UserBurst)
+	g.rooms.SetRate(l.RoomRate, l.RoomBurst)
+	g.connects.SetRate(l.ConnectsPerMinute/60, max(1, int(l.ConnectsPerMinute)))
+}
+
+// SetBlocked bans keys (see IPKey and UserKey) with no expiry, replacing
+// the previous set.
+func (g *Guard) SetBlocked(keys []string) {
+	blocked := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		blocked[k] = struct{}{}
+	}
+	g.mu.Lock()
+	g.blocked = blocked
+	g.mu.Unlock()
+}
+
+func (g *Guard) current() Limits {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limits
+}
+
+// NewConnBucket returns the per-connection bucket for a new client.
+func (g *Guard) NewConnBucket() *Bucket {
+	l := g.current()
+	return NewBucket(l.ConnRate, l.ConnBurst)
+}
+
+// NewStrikes returns a strike counter using the configured window.
+func (g *Guard) NewStrikes() *Strikes {
+	return &Strikes{window: g.current().StrikeWindow}
+}
+
+// MaxStrikes is the number of violations that earns a ban; zero means never.
+func (g *Guard) MaxStrikes() int { return g.current().MaxStrikes }
+
+// AllowConnect reports whether ip may open another connection.
+func (g *Guard) AllowConnect(ip string) bool {
+	if g.Banned(IPKey(ip)) {
+		return false
+	}
+	return g.connects.Allow(ip)
+}
+
+func (g *Guard) AllowUser(username string) bool { return g.users.Allow(username) }
+func (g *Guard) AllowRoom(room string) bool     { return g.rooms.Allow(room) }
+
+// StrikeUser records a violation for username and reports whether the user
+// has now used up MaxStrikes.
+func (g *Guard) StrikeUser(username string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.limits.MaxStrikes <= 0 {
+		return false
+	}
+
+	s, ok := g.strikes[username]
+	if !ok {
+		s = &Strikes{window: g.limits.StrikeWindow}
+		g.strikes[username] = s
+	}
+	if s.Add() >= g.limits.MaxStrikes {
+		delete(g.strikes, username)
+		return true
+	}
+	return false
+}
+
+// Ban blocks key (see IPKey and UserKey) for the configured ban duration.
+func (g *Guard) Ban(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.limits.BanDuration <= 0 {
+		return
+	}
+	g.bans[key] = time.Now().Add(g.limits.BanDuration)
+}
+
+// Banned reports whether key is currently banned.
+func (g *Guard) Banned(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.blocked[key]; ok {
+		return true
+	}
+	until, ok := g.bans[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(g.bans, key)
+		return false
+	}
+	return true
+}
+
+func IPKey(ip string) string         { return "ip:" + ip }
+func UserKey(username string) string { return "user:" + username }
+
+// Strikes counts violations inside a rolling window.
+type Strikes struct {
+	window time.Duration
+	count  int
+	first  time.Time
+}
+
+// Add records a violation and returns the count within the current window.
+func (s *Strikes) Add() int {
+	now := time.Now()
+	if s.count == 0 || (s.window > 0 && now.Sub(s.first) > s.window) {
+		s.count = 0
+		s.first = now
+	}
+	s.count++
+	return s.count
+}
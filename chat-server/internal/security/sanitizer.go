@@ -9,16 +9,12 @@ import (
 
 var (
 	// ANSI escape sequence pattern (CSI + OSC)
-	ansiPattern      = regexp.MustCompile(`\x1b(?:\[[0-9;]*[a-zA-Z]|\][0-9;]*(?:\\\|[a-zA-Z0-9=:])*\x07|[\(\)].|[\[\]#;?].*?(?:\x1b\\|\x07))`)
-	maxMessageLength = 2000
+	ansiPattern = regexp.MustCompile(`\x1b(?:\[[0-9;]*[a-zA-Z]|\][0-9;]*(?:\\\|[a-zA-Z0-9=:])*\x07|[\(\)].|[\[\]#;?].*?(?:\x1b\\|\x07))`)
 )
 
+// SanitizeInput strips escape sequences and control characters. Length
+// limits are enforced when the message is read (see max_body_bytes).
 func SanitizeInput(input string) string {
-	originalLen := len(input)
-
-	if len(input) > maxMessageLength {
-		input = input[:maxMessageLength]
-	}
 	input = ansiPattern.ReplaceAllString(input, "")
 
 	var filtered strings.Builder
@@ -31,7 +27,20 @@ func SanitizeInput(input string) string {
 		}
 	}
 
-	result := filtered.String()
-	fmt.Printf("Sanitized: %d chars -> %d chars\n", originalLen, len(result))
-	return result
+	return filtered.String()
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+const minPasswordLength = 8
+
+// ValidateCredentials checks the shape of a username/password pair before registration.
+func ValidateCredentials(username, password string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username must be 1-32 letters, digits, '.', '_' or '-'")
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
 }
@@ -6,24 +6,50 @@ import (
 	"syscall"
 
 	"github.com/danieljhkim/chat-server/internal/app"
+	"github.com/danieljhkim/chat-server/internal/chatstore"
 	"github.com/danieljhkim/chat-server/internal/config"
 	"github.com/danieljhkim/chat-server/internal/logger"
 	netutil "github.com/danieljhkim/chat-server/internal/net"
 )
 
+// main runs a bare TCP server. cmd/server is the full one, with
+// WebSocket, metrics and config reloads on SIGHUP.
 func main() {
 	if err := config.Load("config"); err != nil {
 		panic(err)
 	}
 
-	log := logger.New(config.Cfg.LogLevel)
+	log := logger.New(config.Get().LogLevel)
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	hub := app.NewHub(log)
-	go hub.Run(ctx)
+	store, err := chatstore.Open(chatstore.Options{
+		Driver:           config.Get().Storage.Driver,
+		Path:             config.Get().Storage.Path,
+		SnapshotEvery:    config.Get().Storage.SnapshotEvery,
+		Fsync:            config.Get().Storage.Fsync,
+		RoomHistoryLimit: config.Get().Storage.RoomHistoryLimit,
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	hub, err := app.NewHub(log, store)
+	if err != nil {
+		panic(err)
+	}
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(ctx)
+		close(hubDone)
+	}()
 
-	if err := netutil.StartTCP(ctx, config.Cfg.ListenAddress, hub, log); err != nil {
+	if err := netutil.StartTCP(ctx, config.Get().ListenAddress, hub, log); err != nil {
 		log.Error("listener error", "err", err)
 	}
+
+	// let the hub notify and drain clients before the deferred store.Close
+	stop()
+	<-hubDone
 }